@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mediafs/internal/service"
+)
+
+const apiKeyUsage = `Usage: mediafs apikey <command> [options]
+
+Commands:
+  create  --name NAME --scopes videos:read,videos:delete,cut:write
+  list
+  revoke  --id ID`
+
+// handleAPIKeyCommand обрабатывает подкоманды `mediafs apikey ...`
+func handleAPIKeyCommand(metaDir string, args []string) {
+	if len(args) == 0 {
+		fmt.Println(apiKeyUsage)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet(cmdAPIKey+" "+args[0], flag.ExitOnError)
+	name := fs.String("name", "", "Key name, e.g. the script using it")
+	scopes := fs.String("scopes", string(service.ScopeVideosRead), "Comma-separated scopes")
+	id := fs.String("id", "", "Key ID")
+	_ = fs.Parse(args[1:])
+
+	apiKeys := service.NewAPIKeyService(filepath.Join(metaDir, "apikeys.json"))
+	if err := apiKeys.Load(); err != nil {
+		log.Fatal("❌ Failed to read apikeys.json: ", err)
+	}
+
+	switch args[0] {
+	case "create":
+		requireFlag(*name, "--name")
+		parsed, err := service.ParseScopes(*scopes)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		plain, key, err := apiKeys.Create(*name, parsed)
+		if err != nil {
+			log.Fatal("❌ Failed to create API key: ", err)
+		}
+		fmt.Printf("✅ API key %s (%s) created. Store it now, it won't be shown again:\n%s\n", key.ID, key.Name, plain)
+
+	case "list":
+		for _, k := range apiKeys.List() {
+			lastUsed := "never"
+			if !k.LastUsedAt.IsZero() {
+				lastUsed = k.LastUsedAt.Format(time.RFC3339)
+			}
+			scopeNames := make([]string, 0, len(k.Scopes))
+			for _, s := range k.Scopes {
+				scopeNames = append(scopeNames, string(s))
+			}
+			fmt.Printf("%-10s %-20s %-40s last used: %s\n", k.ID, k.Name, strings.Join(scopeNames, ","), lastUsed)
+		}
+
+	case "revoke":
+		requireFlag(*id, "--id")
+		if err := apiKeys.Revoke(*id); err != nil {
+			log.Fatal("❌ Failed to revoke API key: ", err)
+		}
+		fmt.Printf("✅ API key %s revoked\n", *id)
+
+	default:
+		fmt.Println(apiKeyUsage)
+		os.Exit(1)
+	}
+}
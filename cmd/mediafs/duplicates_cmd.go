@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"mediafs/internal/service"
+)
+
+// handleDuplicatesCommand печатает группы повторно загруженных видео: `mediafs duplicates [--threshold 0.9]`
+func handleDuplicatesCommand(baseDir, metaDir string, args []string) {
+	fs := flag.NewFlagSet(cmdDuplicates, flag.ExitOnError)
+	threshold := fs.Float64("threshold", service.DefaultDuplicateThreshold, "Minimum share of identical segments, 0-1")
+	_ = fs.Parse(args)
+
+	index := setupVideoIndex(baseDir, metaDir)
+	finder := setupDuplicates(baseDir, metaDir)
+
+	entries, err := index.List()
+	if err != nil {
+		log.Fatal("❌ Failed to list videos: ", err)
+	}
+	groups, err := finder.Find(entries, *threshold)
+	if err != nil {
+		log.Fatal("❌ Failed to find duplicates: ", err)
+	}
+
+	if len(groups) == 0 {
+		fmt.Printf("✅ No duplicates among %d videos\n", len(entries))
+		return
+	}
+
+	var reclaimable int64
+	for i, g := range groups {
+		kind := "identical"
+		if !g.Exact {
+			kind = fmt.Sprintf("%.0f%% shared segments", g.Similarity*100)
+		}
+		fmt.Printf("#%d %s, %s reclaimable\n", i+1, kind, formatBytes(g.Reclaimable))
+		for _, v := range g.Videos {
+			fmt.Printf("   %-12s %-40s %5d segments %10s\n", v.ID, v.Folder, v.SegmentCount, formatBytes(v.Bytes))
+		}
+		reclaimable += g.Reclaimable
+	}
+	fmt.Printf("⚠️  %d duplicate groups among %d videos, %s reclaimable\n", len(groups), len(entries), formatBytes(reclaimable))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -2,17 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
+	"mediafs/internal/entity"
 	"mediafs/internal/handler"
 	"mediafs/internal/middleware"
 	"mediafs/internal/service"
@@ -21,34 +25,87 @@ import (
 const (
 	port          = ":8000"
 	cmdHashPasswd = "hash-password"
+	cmdUser       = "user"
+	cmdAPIKey     = "apikey"
+	cmdReindex    = "reindex"
+	cmdDuplicates = "duplicates"
 )
 
-var enableLogger bool
+var (
+	enableLogger bool
+	tokenTTL     time.Duration
+	refreshTTL   time.Duration
+	signedURLTTL time.Duration
+	watchDelay   time.Duration
+)
 
 func main() {
 	flag.BoolVar(&enableLogger, "log", false, "Enable HTTP request logging")
+	flag.DurationVar(&tokenTTL, "token-ttl", service.DefaultTokenTTL, "Access token lifetime")
+	flag.DurationVar(&refreshTTL, "refresh-ttl", service.DefaultRefreshTTL, "Refresh token lifetime")
+	flag.DurationVar(&signedURLTTL, "signed-url-ttl", service.DefaultSignedURLTTL, "Signed playback URL lifetime")
+	flag.DurationVar(&watchDelay, "watch-debounce", service.DefaultWatchDebounce, "Quiet period before a changed video folder is re-indexed")
+	flag.BoolVar(&entity.FFProbeFallback, "ffprobe", true, "Fall back to ffprobe when MPEG-TS probing finds nothing")
 	flag.Parse()
 
 	baseDir, metaDir := ensureMediaFS()
 
-	if len(os.Args) > 1 && os.Args[1] == cmdHashPasswd {
-		handlePasswordHashing(metaDir)
+	switch flag.Arg(0) {
+	case cmdHashPasswd:
+		handlePasswordHashing(metaDir, flag.Args()[1:])
+		return
+	case cmdUser:
+		handleUserCommand(metaDir, flag.Args()[1:])
+		return
+	case cmdAPIKey:
+		handleAPIKeyCommand(metaDir, flag.Args()[1:])
+		return
+	case cmdReindex:
+		handleReindex(baseDir, metaDir)
+		return
+	case cmdDuplicates:
+		handleDuplicatesCommand(baseDir, metaDir, flag.Args()[1:])
 		return
 	}
 
 	authService := setupAuth(metaDir)
+	signer := setupSigner(authService)
+	apiKeys := setupAPIKeys(metaDir)
+	audit := service.NewAuditService(filepath.Join(metaDir, "audit.log"))
+	index := setupVideoIndex(baseDir, metaDir)
+	shares := setupShares(metaDir, index)
+	collections := setupCollections(metaDir, index)
+	journal := setupJournal(metaDir, index)
+	progress := setupProgress(metaDir)
+	duplicates := setupDuplicates(baseDir, metaDir)
 	cutService := service.NewCutService(baseDir)
+	watcher := service.NewLibraryWatcher(baseDir, index, watchDelay)
+	events := setupEvents(index, watcher)
 
 	// Настройка контекста для управления жизненным циклом
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Инициализация компонентов
-	app := setupFiberApp(baseDir, authService, cutService)
+	app := setupFiberApp(baseDir, authService, apiKeys, signer, audit, shares, index, collections, cutService, watcher, events, journal, progress, duplicates)
 
 	// WaitGroup для всех горутин
 	var wg sync.WaitGroup
 
+	// Наблюдение за папками видео
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Run(ctx)
+	}()
+
+	// Периодическая запись прогресса просмотра
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		progress.Run(ctx)
+	}()
+
 	// Запуск HTTP‑сервера
 	wg.Add(1)
 	go func() {
@@ -72,10 +129,15 @@ func main() {
 		log.Println("🛑 Context canceled, shutting down...")
 	}
 
+	// Закрываем потоки событий, иначе Shutdown будет ждать их бесконечно
+	events.Close()
+
 	// Останавливаем HTTP‑сервер
 	if err := app.Shutdown(); err != nil {
 		log.Printf("❌ Error during shutdown: %v", err)
 	}
+	// Останавливаем фоновые задачи
+	cancel()
 
 	// Ждем завершения всех горутин
 	wg.Wait()
@@ -85,19 +147,117 @@ func main() {
 // setupAuth настраивает сервис аутентификации
 func setupAuth(metaDir string) *service.AuthService {
 	authPath := filepath.Join(metaDir, "auth.json")
-	authService := service.NewAuthService(authPath)
+	authService := service.NewAuthService(service.NewFileAuthStore(authPath))
 
-	if err := authService.Load(); err != nil {
-		log.Fatal("❌ auth.json not found. Create it first with `mediafs hash-password`.")
+	if err := authService.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("❌ Failed to read auth.json: ", err)
+	}
+	if !authService.HasUsers() {
+		log.Fatal("❌ No users found in auth.json. Create one first with `mediafs user add`.")
 	}
+	authService.SetTTL(tokenTTL, refreshTTL)
 
 	return authService
 }
 
+// setupAPIKeys загружает API-ключи, созданные через `mediafs apikey`
+func setupAPIKeys(metaDir string) *service.APIKeyService {
+	apiKeys := service.NewAPIKeyService(filepath.Join(metaDir, "apikeys.json"))
+	if err := apiKeys.Load(); err != nil {
+		log.Fatal("❌ Failed to read apikeys.json: ", err)
+	}
+	return apiKeys
+}
+
+// setupShares загружает анонимные ссылки на видео
+func setupShares(metaDir string, index *service.VideoIndex) *service.ShareService {
+	shares := service.NewShareService(filepath.Join(metaDir, "shares.json"), index)
+	if err := shares.Load(); err != nil {
+		log.Fatal("❌ Failed to read shares.json: ", err)
+	}
+	return shares
+}
+
+// setupVideoIndex загружает индекс метаданных видео
+func setupVideoIndex(baseDir, metaDir string) *service.VideoIndex {
+	index := service.NewVideoIndex(baseDir, filepath.Join(metaDir, "index.json"))
+	if err := index.Load(); err != nil {
+		log.Fatal("❌ Failed to read index.json: ", err)
+	}
+	return index
+}
+
+// setupCollections загружает коллекции видео
+func setupCollections(metaDir string, index *service.VideoIndex) *service.CollectionService {
+	collections := service.NewCollectionService(filepath.Join(metaDir, "collections.json"), index)
+	if err := collections.Load(); err != nil {
+		log.Fatal("❌ Failed to read collections.json: ", err)
+	}
+	return collections
+}
+
+// setupJournal загружает журнал изменений и подписывает его на индекс
+func setupJournal(metaDir string, index *service.VideoIndex) *service.ChangeJournal {
+	journal := service.NewChangeJournal(filepath.Join(metaDir, "changes.json"))
+	if err := journal.Load(); err != nil {
+		log.Fatal("❌ Failed to read changes.json: ", err)
+	}
+	index.OnChange(journal.Record)
+	return journal
+}
+
+// setupProgress загружает прогресс просмотра пользователей
+func setupProgress(metaDir string) *service.ProgressService {
+	progress := service.NewProgressService(filepath.Join(metaDir, "progress.json"))
+	if err := progress.Load(); err != nil {
+		log.Fatal("❌ Failed to read progress.json: ", err)
+	}
+	return progress
+}
+
+// setupDuplicates загружает сохранённые хэши сегментов для поиска копий
+func setupDuplicates(baseDir, metaDir string) *service.DuplicateFinder {
+	duplicates := service.NewDuplicateFinder(baseDir, filepath.Join(metaDir, "fingerprints.json"))
+	if err := duplicates.Load(); err != nil {
+		log.Fatal("❌ Failed to read fingerprints.json: ", err)
+	}
+	return duplicates
+}
+
+// setupEvents направляет изменения библиотеки и обходы в ленту событий
+func setupEvents(index *service.VideoIndex, watcher *service.LibraryWatcher) *service.EventBus {
+	events := service.NewEventBus()
+	index.OnChange(events.PublishVideoChanges)
+	watcher.OnScan(func(p service.ScanProgress) {
+		events.Publish(service.EventJobProgress, p)
+	})
+	return events
+}
+
+// setupSigner создаёт подписчик ссылок на ключе из auth.json
+func setupSigner(authService *service.AuthService) *service.URLSigner {
+	secret, err := authService.URLSecret()
+	if err != nil {
+		log.Fatal("❌ Failed to init URL signing key: ", err)
+	}
+	return service.NewURLSigner(secret, signedURLTTL)
+}
+
 // setupFiberApp настраивает Fiber‑приложение
 func setupFiberApp(baseDir string,
 	authService *service.AuthService,
-	cutService *service.CutService) *fiber.App {
+	apiKeys *service.APIKeyService,
+	signer *service.URLSigner,
+	audit *service.AuditService,
+	shares *service.ShareService,
+	index *service.VideoIndex,
+	collections *service.CollectionService,
+	cutService *service.CutService,
+	watcher *service.LibraryWatcher,
+	events *service.EventBus,
+	journal *service.ChangeJournal,
+	progress *service.ProgressService,
+	duplicates *service.DuplicateFinder) *fiber.App {
 
 	app := fiber.New()
 
@@ -108,22 +268,81 @@ func setupFiberApp(baseDir string,
 	}
 
 	// Аутентификация
-	app.Post("/auth", handler.AuthHandler(authService))
-
-	// Middleware авторизации
-	app.Use(middleware.BearerAuthMiddleware(authService))
+	limiter := service.NewLoginLimiter()
+	limiter.OnLockout(func(e service.LockoutEvent) {
+		detail := "until " + e.Until.UTC().Format(time.RFC3339)
+		if e.IP == "" {
+			detail = "global lockout " + detail
+		}
+		if err := audit.Record(service.AuditEvent{Action: service.AuditLockout, IP: e.IP, Detail: detail}); err != nil {
+			log.Printf("❌ Failed to write audit log: %v", err)
+		}
+	})
+	app.Post("/auth", handler.AuthHandler(authService, limiter, audit))
+	app.Post("/auth/refresh", handler.RefreshHandler(authService))
 
-	// HLS-файловый сервис
-	app.Get("/videos", handler.ListVideos(baseDir))
-	app.Get("/videos/:videoname/*", handler.StreamHLSFile(baseDir))
-	app.Delete("/videos/:videoname", handler.DeleteVideo(baseDir))
+	// Публичные ссылки на отдельные видео; подбор паролей ссылок считается отдельно от входа
+	app.Post("/s/:token", handler.UnlockShare(shares, signer, service.NewLoginLimiter()))
+	app.Get("/s/:token/*", handler.StreamShare(baseDir, shares, signer))
 
-	app.Get("/keyframe/:videoname/:filename", handler.GetKeyFrameFile(baseDir))
-	app.Get("/nsfw/:videoname", handler.GetNsfwFrameList(baseDir))
-	app.Get("/nsfw/:videoname/:filename", handler.GetNsfwFrameFile(baseDir))
+	// Middleware авторизации
+	app.Use(middleware.BearerAuthMiddleware(authService, apiKeys, signer))
+
+	canRead := middleware.RequireScope(service.ScopeVideosRead)
+	canWrite := middleware.RequireScope(service.ScopeVideosWrite)
+	// подписанные ссылки годятся только для файлов видео и плейлистов подборок
+	signed := middleware.AllowSigned()
+
+	app.Post("/auth/logout", middleware.RequireUser(), handler.LogoutHandler(authService, audit))
+
+	// Сессии (устройства) пользователя
+	app.Get("/sessions", middleware.RequireUser(), handler.ListSessions(authService))
+	app.Delete("/sessions/:id", middleware.RequireUser(), handler.RevokeSession(authService))
+
+	// Подписанные ссылки для HLS-плееров
+	app.Post("/sign", canRead, handler.SignURLHandler(signer, index, collections))
+
+	// HLS-файловый сервис; :videoname - путь папки или постоянный ID видео (см. handler.ResolveVideo)
+	video := handler.ResolveVideo(index)
+	app.Get("/videos", canRead, handler.ListVideos(baseDir, index, journal, progress))
+	app.Get("/videos/changes", canRead, handler.ListVideoChanges(journal))
+	app.Get("/browse/*", canRead, handler.Browse(baseDir, index, progress))
+	app.Get("/library/status", canRead, handler.GetLibraryStatus(watcher))
+	app.Get("/stats", canRead, handler.GetStats(baseDir, index))
+	// поиск копий нужен для чистки библиотеки и на первом запуске читает все сегменты
+	app.Get("/duplicates", middleware.RequireScope(service.ScopeVideosDelete), handler.ListDuplicates(index, duplicates))
+	app.Get("/events", canRead, handler.StreamEvents(events))
+	canShare := middleware.RequireScope(service.ScopeSharesWrite)
+	app.Post("/videos/:videoname/shares", canShare, video, handler.CreateShare(shares, index, audit))
+	app.Get("/videos/:videoname/shares", canShare, video, handler.ListShares(shares, index))
+	app.Delete("/videos/:videoname/shares/:id", canShare, video, handler.DeleteShare(shares, index, audit))
+
+	app.Get("/videos/:videoname", canRead, video, handler.GetVideo(baseDir, index, progress))
+	app.Get("/videos/:videoname/meta", canRead, video, handler.GetVideoMeta(index))
+	app.Patch("/videos/:videoname/meta", canWrite, video, handler.PatchVideoMeta(index))
+	app.Get("/videos/:videoname/similar", canRead, video, handler.GetSimilarVideos(baseDir, index, progress))
+	app.Put("/videos/:videoname/progress", canRead, video, handler.PutProgress(index, progress))
+	app.Get("/continue-watching", canRead, handler.ContinueWatching(baseDir, index, progress))
+	app.Get("/videos/:videoname/*", signed, canRead, video, handler.StreamHLSFile(baseDir, signer))
+	app.Delete("/videos/:videoname", middleware.RequireScope(service.ScopeVideosDelete), video, handler.DeleteVideo(baseDir, index, audit))
+
+	app.Get("/keyframe/:videoname/*", canRead, video, handler.GetKeyFrameFile(baseDir))
+	app.Get("/nsfw/:videoname", canRead, video, handler.GetNsfwFrameList(baseDir))
+	app.Get("/nsfw/:videoname/*", canRead, video, handler.GetNsfwFrameFile(baseDir))
+
+	// Коллекции видео
+	app.Get("/collections", canRead, handler.ListCollections(collections))
+	app.Post("/collections", canWrite, handler.CreateCollection(collections))
+	app.Get("/collections/:id", canRead, handler.GetCollection(baseDir, collections, index, progress))
+	app.Patch("/collections/:id", canWrite, handler.UpdateCollection(collections))
+	app.Delete("/collections/:id", canWrite, handler.DeleteCollection(collections))
+	app.Get("/collections/:id/playlist.m3u8", signed, canRead, handler.CollectionPlaylist(collections, signer))
 
 	// Редактирование видео
-	app.Post("/cut/:videoname", handler.CutHandler(cutService))
+	app.Post("/cut/:videoname", middleware.RequireScope(service.ScopeCutWrite), video, handler.CutHandler(cutService, index, audit, events))
+
+	// Журнал аудита
+	app.Get("/audit", middleware.RequireScope(service.ScopeAuditRead), handler.GetAuditLog(audit))
 
 	return app
 }
@@ -148,31 +367,37 @@ func ensureMediaFS() (mediafsPath string, metaPath string) {
 	return mediafsPath, metaPath
 }
 
-// handlePasswordHashing обрабатывает команду hash-password и получает путь к мета‑директории извне
-func handlePasswordHashing(metaDir string) {
+// handlePasswordHashing - устаревшая команда hash-password: задаёт пароль пользователя admin,
+// создавая его при необходимости. Для остальных пользователей есть `mediafs user`.
+func handlePasswordHashing(metaDir string, args []string) {
 	hashCmd := flag.NewFlagSet(cmdHashPasswd, flag.ExitOnError)
 	passwordPtr := hashCmd.String("password", "", "Password to hash and save")
-	_ = hashCmd.Parse(os.Args[2:])
+	_ = hashCmd.Parse(args)
 
 	if *passwordPtr == "" {
 		log.Fatal("❌ Please provide --password")
 	}
 
-	authPath := filepath.Join(metaDir, "auth.json")
-	authService := service.NewAuthService(authPath)
-
-	if _, err := os.Stat(authPath); err == nil {
-		var response string
-		fmt.Printf("⚠️  %s already exists. Overwrite? Type 'yes' to confirm: ", authPath)
-		fmt.Scanln(&response)
-		if response != "yes" {
-			fmt.Println("❌ Aborted.")
-			return
-		}
-	}
+	authService, authPath := loadAuthForCLI(metaDir)
 
-	if err := authService.SetPassword(*passwordPtr); err != nil {
+	var err error
+	if _, exists := authService.User(service.DefaultUsername); exists {
+		err = authService.SetPassword(service.DefaultUsername, *passwordPtr)
+	} else {
+		err = authService.AddUser(service.DefaultUsername, *passwordPtr, service.RoleAdmin)
+	}
+	if err != nil {
 		log.Fatal("Failed to save password:", err)
 	}
-	fmt.Println("✅ Password hash saved to:", authPath)
+	fmt.Printf("✅ Password hash for %q saved to: %s\n", service.DefaultUsername, authPath)
+}
+
+// handleReindex заново строит индекс метаданных всех видео
+func handleReindex(baseDir, metaDir string) {
+	index := service.NewVideoIndex(baseDir, filepath.Join(metaDir, "index.json"))
+	count, err := index.Rebuild()
+	if err != nil {
+		log.Fatal("❌ Failed to rebuild index: ", err)
+	}
+	fmt.Printf("✅ Indexed %d videos\n", count)
 }
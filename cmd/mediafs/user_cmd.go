@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"mediafs/internal/service"
+)
+
+const userUsage = `Usage: mediafs user <command> [options]
+
+Commands:
+  add       --username NAME --password PASS [--role viewer|editor|admin]
+  remove    --username NAME
+  set-role  --username NAME --role viewer|editor|admin
+  passwd    --username NAME --password PASS
+  totp-enable   --username NAME
+  totp-disable  --username NAME
+  list`
+
+// handleUserCommand обрабатывает подкоманды `mediafs user ...`
+func handleUserCommand(metaDir string, args []string) {
+	if len(args) == 0 {
+		fmt.Println(userUsage)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet(cmdUser+" "+args[0], flag.ExitOnError)
+	username := fs.String("username", "", "User name")
+	password := fs.String("password", "", "User password")
+	roleName := fs.String("role", string(service.RoleViewer), "User role: viewer, editor or admin")
+	_ = fs.Parse(args[1:])
+
+	authService, authPath := loadAuthForCLI(metaDir)
+
+	switch args[0] {
+	case "add":
+		requireFlag(*username, "--username")
+		requireFlag(*password, "--password")
+		role := mustParseRole(*roleName)
+		if err := authService.AddUser(*username, *password, role); err != nil {
+			log.Fatal("❌ Failed to add user: ", err)
+		}
+		fmt.Printf("✅ User %q (%s) saved to: %s\n", *username, role, authPath)
+
+	case "remove":
+		requireFlag(*username, "--username")
+		if err := authService.RemoveUser(*username); err != nil {
+			log.Fatal("❌ Failed to remove user: ", err)
+		}
+		fmt.Printf("✅ User %q removed\n", *username)
+
+	case "set-role":
+		requireFlag(*username, "--username")
+		role := mustParseRole(*roleName)
+		if err := authService.SetRole(*username, role); err != nil {
+			log.Fatal("❌ Failed to set role: ", err)
+		}
+		fmt.Printf("✅ User %q is now %s\n", *username, role)
+
+	case "passwd":
+		requireFlag(*username, "--username")
+		requireFlag(*password, "--password")
+		if err := authService.SetPassword(*username, *password); err != nil {
+			log.Fatal("❌ Failed to set password: ", err)
+		}
+		fmt.Printf("✅ Password for %q updated\n", *username)
+
+	case "totp-enable":
+		requireFlag(*username, "--username")
+		enrollment, err := authService.EnableTOTP(*username)
+		if err != nil {
+			log.Fatal("❌ Failed to enable TOTP: ", err)
+		}
+		fmt.Printf("✅ TOTP enabled for %q. Add this URI to your authenticator app:\n%s\n\n", *username, enrollment.URI)
+		fmt.Println("Recovery codes (each works once, store them safely):")
+		for _, code := range enrollment.RecoveryCodes {
+			fmt.Println("  " + code)
+		}
+
+	case "totp-disable":
+		requireFlag(*username, "--username")
+		if err := authService.DisableTOTP(*username); err != nil {
+			log.Fatal("❌ Failed to disable TOTP: ", err)
+		}
+		fmt.Printf("✅ TOTP disabled for %q\n", *username)
+
+	case "list":
+		for _, u := range authService.Users() {
+			totp := ""
+			if u.TOTPSecret != "" {
+				totp = "totp"
+			}
+			fmt.Printf("%-20s %-8s %s\n", u.Username, u.Role, totp)
+		}
+
+	default:
+		fmt.Println(userUsage)
+		os.Exit(1)
+	}
+}
+
+// loadAuthForCLI загружает auth.json, если он уже существует
+func loadAuthForCLI(metaDir string) (*service.AuthService, string) {
+	authPath := filepath.Join(metaDir, "auth.json")
+	authService := service.NewAuthService(service.NewFileAuthStore(authPath))
+
+	if err := authService.Load(); err != nil && !os.IsNotExist(err) {
+		log.Fatal("❌ Failed to read auth.json: ", err)
+	}
+	return authService, authPath
+}
+
+func mustParseRole(name string) service.Role {
+	role, err := service.ParseRole(name)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	return role
+}
+
+func requireFlag(value, name string) {
+	if value == "" {
+		log.Fatalf("❌ Please provide %s", name)
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/grafov/m3u8 v0.12.1
 	github.com/zeebo/blake3 v0.2.4
 	golang.org/x/crypto v0.37.0
+	golang.org/x/sys v0.32.0
 )
 
 require (
@@ -20,5 +21,4 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.60.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
 )
@@ -0,0 +1,141 @@
+package entity
+
+import (
+	"image"
+	"image/jpeg"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxFrameHashes ограничивает число кадров, по которым считается отпечаток видео:
+// из длинного ряда ключевых кадров берутся равномерно распределённые
+const maxFrameHashes = 100
+
+// minFrameContrast - наименьший разброс яркости (0-255) по сетке dHash; кадры ровнее
+// (чёрные, белые, заставки одного цвета) совпадают с чем угодно и пропускаются
+const minFrameContrast = 8
+
+// FrameHashes считает dHash ключевых кадров из keyframes/ в порядке имён файлов.
+// Пустой результат означает, что кадров нет или ни один не удалось разобрать.
+func (m *MediaInfo) FrameHashes() []uint64 {
+	dir := filepath.Join(m.EntryPath, "keyframes")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (ext == ".jpg" || ext == ".jpeg") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) > maxFrameHashes {
+		sampled := make([]string, maxFrameHashes)
+		for i := range sampled {
+			sampled[i] = names[i*len(names)/maxFrameHashes]
+		}
+		names = sampled
+	}
+
+	hashes := make([]uint64, 0, len(names))
+	for _, name := range names {
+		if hash, ok := hashFrame(filepath.Join(dir, name)); ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// HammingDistance - число различающихся бит двух хэшей кадров
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func hashFrame(path string) (uint64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		return 0, false
+	}
+	return dHash(img)
+}
+
+// dHash уменьшает кадр до 9x8 по яркости и записывает бит на каждую пару соседних
+// по горизонтали клеток: светлее ли левая. Перекодирование и масштаб на хэш почти не влияют.
+func dHash(img image.Image) (uint64, bool) {
+	const w, h = 9, 8
+	b := img.Bounds()
+	if b.Dx() < w || b.Dy() < h {
+		return 0, false
+	}
+
+	var grid [h][w]float64
+	lo, hi := 255.0, 0.0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cell := image.Rect(
+				b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h,
+				b.Min.X+(x+1)*b.Dx()/w, b.Min.Y+(y+1)*b.Dy()/h,
+			)
+			grid[y][x] = meanLuma(img, cell)
+			lo, hi = min(lo, grid[y][x]), max(hi, grid[y][x])
+		}
+	}
+	if hi-lo < minFrameContrast {
+		return 0, false
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, true
+}
+
+// meanLuma - средняя яркость прямоугольника; берётся не больше 16x16 точек, этого для
+// сетки 9x8 достаточно и не нужно обходить весь кадр
+func meanLuma(img image.Image, r image.Rectangle) float64 {
+	const samples = 16
+	stepX, stepY := max(1, r.Dx()/samples), max(1, r.Dy()/samples)
+
+	var sum float64
+	var n int
+	for y := r.Min.Y; y < r.Max.Y; y += stepY {
+		for x := r.Min.X; x < r.Max.X; x += stepX {
+			sum += luma(img, x, y)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// luma читает яркость точки; JPEG обычно раскодируется в YCbCr или Gray, где она хранится как есть
+func luma(img image.Image, x, y int) float64 {
+	switch im := img.(type) {
+	case *image.YCbCr:
+		return float64(im.Y[im.YOffset(x, y)])
+	case *image.Gray:
+		return float64(im.GrayAt(x, y).Y)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (299*float64(r) + 587*float64(g) + 114*float64(b)) / 1000 / 257
+}
@@ -21,6 +21,7 @@ type cachedInfo struct {
 	resolution    string
 	segmentCount  int
 	avgSegmentDur float64
+	streams       *StreamInfo
 	loaded        bool
 }
 
@@ -62,6 +63,12 @@ func (p *Playlist) AvgSegmentDuration() float64 {
 	return p.cached.avgSegmentDur
 }
 
+// Streams - кодеки, частота кадров, битрейт и дорожки; nil, если сегменты не MPEG-TS
+func (p *Playlist) Streams() *StreamInfo {
+	p.ensureCached()
+	return p.cached.streams
+}
+
 func (p *Playlist) ensureCached() {
 	if p.cached != nil && p.cached.loaded {
 		return
@@ -74,6 +81,8 @@ func (p *Playlist) ensureCached() {
 	avgSegmentDur := 0.0
 
 	// parse playlist
+	var first, last *TSInfo
+	discontinuity := false
 	pl, err := p.parseMediaPlaylist()
 	if err == nil {
 		dir := filepath.Dir(p.Path)
@@ -83,20 +92,31 @@ func (p *Playlist) ensureCached() {
 			}
 			totalSegDuration += seg.Duration
 			segmentCount++
+			discontinuity = discontinuity || seg.Discontinuity
 
 			tsPath := filepath.Join(dir, filepath.FromSlash(seg.URI))
 			if info, err := os.Stat(tsPath); err == nil && !info.IsDir() {
 				size += info.Size()
 			}
 		}
+		first, last = p.probeEdges(pl)
 	}
 
 	resolution = p.extractResolutionFromPlaylist()
-	if resolution == "" {
+	if resolution == "" && first != nil {
+		resolution = first.Resolution()
+	}
+	if resolution == "" && FFProbeFallback {
 		resolution = p.FFProbeResolution()
 	}
-	
-	duration := int(math.Round(p.ffprobeDuration()))
+
+	duration := 0
+	if !discontinuity {
+		duration = int(math.Round(ptsDuration(first, last, totalSegDuration)))
+	}
+	if duration == 0 && FFProbeFallback {
+		duration = int(math.Round(p.ffprobeDuration()))
+	}
 	if duration == 0 && segmentCount > 0 {
 		duration = int(math.Round(totalSegDuration))
 	}
@@ -110,10 +130,53 @@ func (p *Playlist) ensureCached() {
 		resolution:    resolution,
 		segmentCount:  segmentCount,
 		avgSegmentDur: avgSegmentDur,
+		streams:       newStreamInfo(first, size, totalSegDuration),
 		loaded:        true,
 	}
 }
 
+// probeEdges разбирает первый и последний сегменты: первого хватает для состава потоков
+// и размера кадра, а вместе с последним они дают длительность по PTS
+func (p *Playlist) probeEdges(pl *m3u8.MediaPlaylist) (*TSInfo, *TSInfo) {
+	var uris []string
+	for _, seg := range pl.Segments {
+		if seg != nil && seg.URI != "" {
+			uris = append(uris, seg.URI)
+		}
+	}
+	if len(uris) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Dir(p.Path)
+	first, err := ProbeTS(filepath.Join(dir, filepath.FromSlash(uris[0])))
+	if err != nil {
+		return nil, nil
+	}
+	if len(uris) == 1 {
+		return first, first
+	}
+	last, err := ProbeTS(filepath.Join(dir, filepath.FromSlash(uris[len(uris)-1])))
+	if err != nil {
+		return first, nil
+	}
+	return first, last
+}
+
+// ptsDuration - длительность от первой отметки первого сегмента до конца последнего.
+// Если она заметно расходится с суммой EXTINF (отметки сбрасывались посреди записи), возвращает 0.
+func ptsDuration(first, last *TSInfo, extinf float64) float64 {
+	if first == nil || last == nil || first.StartPTS < 0 || last.EndPTS < 0 {
+		return 0
+	}
+	ticks := ((last.EndPTS-first.StartPTS)%ptsWrap + ptsWrap) % ptsWrap
+	duration := float64(ticks) / ptsClock
+	if extinf > 0 && math.Abs(duration-extinf) > 1+extinf*0.1 {
+		return 0
+	}
+	return duration
+}
+
 func (p *Playlist) extractResolutionFromPlaylist() string {
 	f, err := os.Open(p.Path)
 	if err != nil {
@@ -136,6 +199,10 @@ func (p *Playlist) extractResolutionFromPlaylist() string {
 	return ""
 }
 
+// FFProbeFallback разрешает запускать ffprobe, когда разбор MPEG-TS ничего не дал
+// (например, сегменты в fMP4). В минимальных контейнерах без ffprobe его лучше выключить.
+var FFProbeFallback = true
+
 func (p *Playlist) FFProbeResolution() string {
 	pl, err := p.parseMediaPlaylist()
 	if err != nil {
@@ -197,6 +264,21 @@ func (p *Playlist) ffprobeDuration() float64 {
 	return duration
 }
 
+// MediaSegments возвращает сегменты медиа-плейлиста в порядке воспроизведения
+func (p *Playlist) MediaSegments() ([]*m3u8.MediaSegment, error) {
+	pl, err := p.parseMediaPlaylist()
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]*m3u8.MediaSegment, 0, pl.Count())
+	for _, seg := range pl.Segments {
+		if seg != nil && seg.URI != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments, nil
+}
+
 func (p *Playlist) parseMediaPlaylist() (*m3u8.MediaPlaylist, error) {
 	count := estimateSegmentCount(p.Path)
 
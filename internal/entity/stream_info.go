@@ -0,0 +1,66 @@
+package entity
+
+import "math"
+
+// StreamInfo - техническое описание видео по его первому сегменту
+type StreamInfo struct {
+	VideoCodec   string  `json:"video_codec,omitempty"`
+	VideoProfile string  `json:"video_profile,omitempty"`
+	VideoLevel   string  `json:"video_level,omitempty"`
+	FrameRate    float64 `json:"frame_rate,omitempty"`
+	// Bitrate - средний битрейт всех сегментов, бит/с
+	Bitrate         int         `json:"bitrate,omitempty"`
+	AudioCodec      string      `json:"audio_codec,omitempty"`
+	AudioChannels   int         `json:"audio_channels,omitempty"`
+	AudioSampleRate int         `json:"audio_sample_rate,omitempty"`
+	AudioTracks     []TrackInfo `json:"audio_tracks,omitempty"`
+	SubtitleTracks  []TrackInfo `json:"subtitle_tracks,omitempty"`
+}
+
+// TrackInfo - одна звуковая дорожка или дорожка субтитров
+type TrackInfo struct {
+	PID        int    `json:"pid"`
+	Codec      string `json:"codec"`
+	Language   string `json:"language,omitempty"`
+	Profile    string `json:"profile,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+}
+
+// newStreamInfo собирает описание из разбора первого сегмента; основной звук - первая дорожка
+func newStreamInfo(first *TSInfo, sizeBytes int64, duration float64) *StreamInfo {
+	if first == nil {
+		return nil
+	}
+	info := &StreamInfo{
+		VideoCodec:   first.VideoCodec,
+		VideoProfile: first.VideoProfile,
+		VideoLevel:   first.VideoLevel,
+		FrameRate:    math.Round(first.FrameRate*1000) / 1000,
+	}
+	if duration > 0 {
+		info.Bitrate = int(math.Round(float64(sizeBytes) * 8 / duration))
+	}
+
+	for _, s := range first.Streams {
+		track := TrackInfo{
+			PID:        int(s.PID),
+			Codec:      s.Codec,
+			Language:   s.Language,
+			Profile:    s.Profile,
+			Channels:   s.Channels,
+			SampleRate: s.SampleRate,
+		}
+		switch s.Kind {
+		case "audio":
+			info.AudioTracks = append(info.AudioTracks, track)
+		case "subtitle":
+			info.SubtitleTracks = append(info.SubtitleTracks, track)
+		}
+	}
+	if len(info.AudioTracks) > 0 {
+		main := info.AudioTracks[0]
+		info.AudioCodec, info.AudioChannels, info.AudioSampleRate = main.Codec, main.Channels, main.SampleRate
+	}
+	return info
+}
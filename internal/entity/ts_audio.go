@@ -0,0 +1,129 @@
+package entity
+
+// audioHeader - параметры звука из заголовка первого кадра элементарного потока
+type audioHeader struct {
+	Profile    string
+	Channels   int
+	SampleRate int
+}
+
+var (
+	aacSampleRates  = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+	aacProfiles     = []string{"Main", "LC", "SSR", "LTP"}
+	ac3SampleRates  = []int{48000, 44100, 32000}
+	eac3HalfRates   = []int{24000, 22050, 16000}
+	ac3Channels     = []int{2, 1, 2, 3, 3, 4, 4, 5}
+	mp3SampleRates1 = []int{44100, 48000, 32000}
+)
+
+// parseAudioHeader ищет в начале PES кадр известного кодека и читает его заголовок
+func parseAudioHeader(codec string, es []byte) (*audioHeader, bool) {
+	for i := 0; i+7 <= len(es); i++ {
+		var h *audioHeader
+		switch codec {
+		case "aac":
+			h = parseADTS(es[i:])
+		case "ac3":
+			h = parseAC3(es[i:])
+		case "eac3":
+			h = parseEAC3(es[i:])
+		case "mp3":
+			h = parseMPEGAudio(es[i:])
+		default:
+			return nil, false
+		}
+		if h != nil {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+func parseADTS(b []byte) *audioHeader {
+	if b[0] != 0xff || b[1]&0xf6 != 0xf0 {
+		return nil
+	}
+	rate := int(b[2] >> 2 & 0x0f)
+	if rate >= len(aacSampleRates) {
+		return nil
+	}
+	channels := int(b[2]&0x01)<<2 | int(b[3]>>6)
+	if channels == 7 {
+		channels = 8
+	}
+	return &audioHeader{
+		Profile:    aacProfiles[b[2]>>6],
+		Channels:   channels,
+		SampleRate: aacSampleRates[rate],
+	}
+}
+
+func parseAC3(b []byte) *audioHeader {
+	// заголовок с acmod и lfeon занимает 8 байт, а parseAudioHeader гарантирует только 7
+	if len(b) < 8 || b[0] != 0x0b || b[1] != 0x77 {
+		return nil
+	}
+	fscod := b[4] >> 6
+	if fscod >= 3 || b[5]>>3 > 10 {
+		return nil
+	}
+
+	// acmod, затем необязательные поля микширования перед lfeon - всего не больше 8 бит
+	r := &bitReader{data: b[6:8]}
+	acmod, _ := r.bits(3)
+	if acmod&1 != 0 && acmod != 1 {
+		_ = r.skip(2)
+	}
+	if acmod&4 != 0 {
+		_ = r.skip(2)
+	}
+	if acmod == 2 {
+		_ = r.skip(2)
+	}
+	lfe, _ := r.bit()
+	return &audioHeader{Channels: ac3Channels[acmod] + int(lfe), SampleRate: ac3SampleRates[fscod]}
+}
+
+func parseEAC3(b []byte) *audioHeader {
+	if b[0] != 0x0b || b[1] != 0x77 || b[5]>>3 <= 10 {
+		return nil
+	}
+	fscod := b[4] >> 6
+	rate := 0
+	if fscod == 3 {
+		fscod2 := b[4] >> 4 & 0x03
+		if fscod2 == 3 {
+			return nil
+		}
+		rate = eac3HalfRates[fscod2]
+	} else {
+		rate = ac3SampleRates[fscod]
+	}
+	acmod := b[4] >> 1 & 0x07
+	lfe := int(b[4] & 0x01)
+	return &audioHeader{Channels: ac3Channels[acmod] + lfe, SampleRate: rate}
+}
+
+func parseMPEGAudio(b []byte) *audioHeader {
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return nil
+	}
+	version := b[1] >> 3 & 0x03
+	layer := b[1] >> 1 & 0x03
+	rateIndex := b[2] >> 2 & 0x03
+	if version == 1 || layer == 0 || rateIndex == 3 || b[2]>>4 == 0x0f {
+		return nil
+	}
+	rate := mp3SampleRates1[rateIndex]
+	switch version {
+	case 2: // MPEG-2
+		rate /= 2
+	case 0: // MPEG-2.5
+		rate /= 4
+	}
+	channels := 2
+	if b[3]>>6 == 3 {
+		channels = 1
+	}
+	return &audioHeader{Channels: channels, SampleRate: rate}
+}
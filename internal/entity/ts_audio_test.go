@@ -0,0 +1,28 @@
+package entity
+
+import "testing"
+
+func TestParseAudioHeaderShortAC3(t *testing.T) {
+	// синхрослово AC-3 в последних 7 байтах PES: заголовок обрезан
+	es := []byte{0x00, 0x00, 0x0b, 0x77, 0x00, 0x00, 0x00, 0x50, 0x40}
+	if h, ok := parseAudioHeader("ac3", es); ok {
+		t.Errorf("parseAudioHeader(truncated ac3) = %+v, want no header", h)
+	}
+
+	full := []byte{0x0b, 0x77, 0x00, 0x00, 0x00, 0x50, 0x40, 0x00}
+	h, ok := parseAudioHeader("ac3", full)
+	if !ok || h.SampleRate != 48000 {
+		t.Errorf("parseAudioHeader(ac3) = %+v, %v, want 48000 Hz", h, ok)
+	}
+}
+
+func FuzzParseAudioHeader(f *testing.F) {
+	f.Add([]byte{0x0b, 0x77, 0x00, 0x00, 0x00, 0x50, 0x40})
+	f.Add([]byte{0xff, 0xf1, 0x50, 0x80, 0x00, 0x1f, 0xfc})
+	f.Add([]byte{0xff, 0xfb, 0x90, 0x64, 0x00, 0x00, 0x00})
+	f.Fuzz(func(t *testing.T, es []byte) {
+		for _, codec := range []string{"aac", "ac3", "eac3", "mp3"} {
+			parseAudioHeader(codec, es)
+		}
+	})
+}
@@ -0,0 +1,381 @@
+package entity
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	ptsClock     = 90000
+	ptsWrap      = 1 << 33
+	// первый видеокадр с SPS почти всегда в первом PES; дальше искать смысла нет
+	maxSPSAttempts = 16
+)
+
+var ErrNotTransportStream = errors.New("not an MPEG-TS file")
+
+// TSStream - элементарный поток из PMT
+type TSStream struct {
+	PID        uint16
+	StreamType byte
+	Codec      string
+	Kind       string // video, audio, subtitle, data
+	Language   string
+	// Profile, Channels и SampleRate известны для аудио, если удалось разобрать заголовок кадра
+	Profile    string
+	Channels   int
+	SampleRate int
+}
+
+// TSInfo - то, что удалось узнать из одного сегмента MPEG-TS без ffprobe
+type TSInfo struct {
+	Streams      []TSStream
+	VideoCodec   string
+	VideoProfile string
+	VideoLevel   string
+	Width        int
+	Height       int
+	// FrameRate - кадров в секунду по отметкам PTS видеопотока
+	FrameRate float64
+	// StartPTS и EndPTS в тиках 90 кГц; EndPTS включает длительность последнего кадра.
+	// -1, если отметок времени в сегменте нет.
+	StartPTS int64
+	EndPTS   int64
+}
+
+// Duration - длительность сегмента по отметкам времени, секунды
+func (i *TSInfo) Duration() float64 {
+	if i.StartPTS < 0 || i.EndPTS < 0 {
+		return 0
+	}
+	return float64(i.EndPTS-i.StartPTS) / ptsClock
+}
+
+// Resolution - размер кадра в виде WxH, как у ffprobe
+func (i *TSInfo) Resolution() string {
+	if i.Width <= 0 || i.Height <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", i.Width, i.Height)
+}
+
+// ProbeTS читает сегмент MPEG-TS: PAT и PMT для состава потоков и языков, SPS H.264/HEVC
+// для профиля и размера кадра, заголовки аудиокадров для каналов и частоты, а отметки PTS
+// основного потока - для длительности и частоты кадров
+func ProbeTS(path string) (*TSInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := newTSDemuxer()
+	r := bufio.NewReaderSize(f, 64*tsPacketSize)
+	packet := make([]byte, tsPacketSize)
+	for {
+		if _, err := io.ReadFull(r, packet[:1]); err != nil {
+			break
+		}
+		if packet[0] != tsSyncByte {
+			// потеря синхронизации: ищем следующий байт 0x47
+			continue
+		}
+		if _, err := io.ReadFull(r, packet[1:]); err != nil {
+			break
+		}
+		d.packet(packet)
+	}
+	if d.pmtPID < 0 {
+		return nil, ErrNotTransportStream
+	}
+	return d.finish(), nil
+}
+
+type ptsTrack struct {
+	first int64
+	min   int64
+	max   int64
+	count int64
+}
+
+type tsDemuxer struct {
+	pmtPID   int
+	info     TSInfo
+	videoPID int
+	timePID  int
+	es       []byte
+	attempts int
+	pts      *ptsTrack
+	// аудиопотоки, заголовок которых ещё не разобран: PID -> индекс в Streams
+	audio map[int]int
+}
+
+func newTSDemuxer() *tsDemuxer {
+	return &tsDemuxer{
+		pmtPID:   -1,
+		videoPID: -1,
+		timePID:  -1,
+		info:     TSInfo{StartPTS: -1, EndPTS: -1},
+		audio:    map[int]int{},
+	}
+}
+
+func (d *tsDemuxer) packet(p []byte) {
+	start := p[1]&0x40 != 0
+	pid := int(p[1]&0x1f)<<8 | int(p[2])
+	adaptation := p[3] >> 4 & 0x3
+
+	offset := 4
+	if adaptation&0x2 != 0 {
+		offset += 1 + int(p[4])
+	}
+	if adaptation&0x1 == 0 || offset >= tsPacketSize {
+		return
+	}
+	payload := p[offset:]
+
+	switch {
+	case pid == 0 && start:
+		d.pat(payload)
+	case pid == d.pmtPID && start && d.info.Streams == nil:
+		d.pmt(payload)
+	case pid == d.videoPID || pid == d.timePID:
+		d.pes(pid, start, payload)
+	}
+	if _, ok := d.audio[pid]; ok && start {
+		d.audioHeader(pid, payload)
+	}
+}
+
+// section возвращает тело PSI-секции без заголовка и CRC; секция должна уместиться в пакет
+func section(payload []byte) ([]byte, bool) {
+	pointer := int(payload[0])
+	if 1+pointer+3 > len(payload) {
+		return nil, false
+	}
+	s := payload[1+pointer:]
+	length := int(s[1]&0x0f)<<8 | int(s[2])
+	if length < 9 || 3+length > len(s) {
+		return nil, false
+	}
+	return s[:3+length-4], true
+}
+
+func (d *tsDemuxer) pat(payload []byte) {
+	s, ok := section(payload)
+	if !ok || s[0] != 0x00 {
+		return
+	}
+	for i := 8; i+4 <= len(s); i += 4 {
+		program := int(s[i])<<8 | int(s[i+1])
+		if program != 0 {
+			d.pmtPID = int(s[i+2]&0x1f)<<8 | int(s[i+3])
+			return
+		}
+	}
+}
+
+func (d *tsDemuxer) pmt(payload []byte) {
+	s, ok := section(payload)
+	if !ok || s[0] != 0x02 || len(s) < 12 {
+		return
+	}
+	d.info.Streams = []TSStream{}
+	i := 12 + (int(s[10]&0x0f)<<8 | int(s[11]))
+	for i+5 <= len(s) {
+		streamType := s[i]
+		pid := uint16(s[i+1]&0x1f)<<8 | uint16(s[i+2])
+		infoLen := int(s[i+3]&0x0f)<<8 | int(s[i+4])
+		end := min(i+5+infoLen, len(s))
+		descriptors := s[i+5 : end]
+		i = end
+
+		codec, kind := streamCodec(streamType, descriptors)
+		d.info.Streams = append(d.info.Streams, TSStream{
+			PID:        pid,
+			StreamType: streamType,
+			Codec:      codec,
+			Kind:       kind,
+			Language:   streamLanguage(descriptors),
+		})
+		if kind == "audio" {
+			d.audio[int(pid)] = len(d.info.Streams) - 1
+		}
+		switch {
+		case kind == "video" && d.videoPID < 0:
+			d.videoPID = int(pid)
+			d.timePID = int(pid)
+			d.info.VideoCodec = codec
+		case kind == "audio" && d.timePID < 0:
+			d.timePID = int(pid)
+		}
+	}
+}
+
+// streamCodec называет кодек по stream_type, а для приватных потоков - по дескрипторам
+func streamCodec(streamType byte, descriptors []byte) (string, string) {
+	switch streamType {
+	case 0x01, 0x02:
+		return "mpeg2video", "video"
+	case 0x10:
+		return "mpeg4", "video"
+	case 0x1b:
+		return "h264", "video"
+	case 0x24:
+		return "hevc", "video"
+	case 0x03, 0x04:
+		return "mp3", "audio"
+	case 0x0f:
+		return "aac", "audio"
+	case 0x11:
+		return "aac_latm", "audio"
+	case 0x81:
+		return "ac3", "audio"
+	case 0x87:
+		return "eac3", "audio"
+	case 0x15:
+		return "timed_id3", "data"
+	case 0x06:
+		for i := 0; i+2 <= len(descriptors); i += 2 + int(descriptors[i+1]) {
+			switch descriptors[i] {
+			case 0x59:
+				return "dvb_subtitle", "subtitle"
+			case 0x56:
+				return "dvb_teletext", "subtitle"
+			case 0x6a:
+				return "ac3", "audio"
+			case 0x7a:
+				return "eac3", "audio"
+			}
+		}
+	}
+	return fmt.Sprintf("0x%02x", streamType), "data"
+}
+
+// streamLanguage берёт код языка ISO 639 из дескриптора языка или DVB-субтитров/телетекста
+func streamLanguage(descriptors []byte) string {
+	for i := 0; i+2 <= len(descriptors); i += 2 + int(descriptors[i+1]) {
+		tag, length := descriptors[i], int(descriptors[i+1])
+		if (tag == 0x0a || tag == 0x59 || tag == 0x56) && length >= 3 && i+5 <= len(descriptors) {
+			return string(descriptors[i+2 : i+5])
+		}
+	}
+	return ""
+}
+
+// audioHeader разбирает заголовок первого аудиокадра в начале PES
+func (d *tsDemuxer) audioHeader(pid int, payload []byte) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return
+	}
+	es := payload[min(9+int(payload[8]), len(payload)):]
+	stream := &d.info.Streams[d.audio[pid]]
+	if h, ok := parseAudioHeader(stream.Codec, es); ok {
+		stream.Profile, stream.Channels, stream.SampleRate = h.Profile, h.Channels, h.SampleRate
+		delete(d.audio, pid)
+	}
+}
+
+func (d *tsDemuxer) pes(pid int, start bool, payload []byte) {
+	if start {
+		if pid == d.videoPID && len(d.es) > 0 && d.info.Width == 0 {
+			d.findSPS()
+		}
+		if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+			return
+		}
+		headerLen := int(payload[8])
+		if payload[7]&0x80 != 0 && len(payload) >= 14 && pid == d.timePID {
+			d.trackPTS(readPTS(payload[9:14]))
+		}
+		if 9+headerLen > len(payload) {
+			return
+		}
+		payload = payload[9+headerLen:]
+	}
+	if pid == d.videoPID && d.info.Width == 0 && d.attempts < maxSPSAttempts {
+		d.es = append(d.es, payload...)
+	}
+}
+
+func readPTS(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// trackPTS копит отметки относительно первой, чтобы переход PTS через 2^33 не ломал длительность
+func (d *tsDemuxer) trackPTS(pts int64) {
+	if d.pts == nil {
+		d.pts = &ptsTrack{first: pts}
+	}
+	rel := (pts - d.pts.first + ptsWrap) % ptsWrap
+	if rel > ptsWrap/2 {
+		rel -= ptsWrap
+	}
+	d.pts.min = min(d.pts.min, rel)
+	d.pts.max = max(d.pts.max, rel)
+	d.pts.count++
+}
+
+// findSPS ищет SPS в накопленном PES видеопотока
+func (d *tsDemuxer) findSPS() {
+	es := d.es
+	d.es = d.es[:0]
+	d.attempts++
+
+	for len(es) > 0 {
+		i := bytes.Index(es, []byte{0, 0, 1})
+		if i < 0 {
+			return
+		}
+		es = es[i+3:]
+		end := bytes.Index(es, []byte{0, 0, 1})
+		nal := es
+		if end >= 0 {
+			nal = es[:end]
+		}
+		if len(nal) < 2 {
+			continue
+		}
+
+		var sps *SPSInfo
+		var err error
+		switch {
+		case d.info.VideoCodec == "h264" && nal[0]&0x1f == 7:
+			sps, err = parseH264SPS(nal[1:])
+		case d.info.VideoCodec == "hevc" && nal[0]>>1&0x3f == 33:
+			sps, err = parseHEVCSPS(nal[2:])
+		}
+		if err == nil && sps != nil && sps.Width > 0 && sps.Height > 0 {
+			d.info.Width, d.info.Height = sps.Width, sps.Height
+			d.info.VideoProfile, d.info.VideoLevel = sps.Profile, sps.Level
+			d.es = nil
+			return
+		}
+	}
+}
+
+func (d *tsDemuxer) finish() *TSInfo {
+	if d.info.Width == 0 && len(d.es) > 0 {
+		d.findSPS()
+	}
+	if d.pts != nil {
+		span := d.pts.max - d.pts.min
+		// последний кадр тоже длится: добавляем средний интервал между отметками
+		if d.pts.count > 1 {
+			span += span / (d.pts.count - 1)
+		}
+		d.info.StartPTS = d.pts.first + d.pts.min
+		d.info.EndPTS = d.info.StartPTS + span
+		if d.timePID == d.videoPID && span > 0 {
+			d.info.FrameRate = float64(d.pts.count) * ptsClock / float64(span)
+		}
+	}
+	info := d.info
+	return &info
+}
@@ -0,0 +1,414 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errShortSPS = errors.New("sps is truncated")
+
+// bitReader читает RBSP побитно, в том числе exp-Golomb коды H.264/HEVC
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) bit() (uint, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errShortSPS
+	}
+	b := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+	r.pos++
+	return uint(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint, error) {
+	var v uint
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+func (r *bitReader) skip(n int) error {
+	if r.pos+n > len(r.data)*8 {
+		return errShortSPS
+	}
+	r.pos += n
+	return nil
+}
+
+// ue - беззнаковый exp-Golomb
+func (r *bitReader) ue() (uint, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errShortSPS
+		}
+	}
+	rest, err := r.bits(zeros)
+	if err != nil {
+		return 0, err
+	}
+	return 1<<zeros - 1 + rest, nil
+}
+
+// se - знаковый exp-Golomb
+func (r *bitReader) se() (int, error) {
+	v, err := r.ue()
+	if err != nil {
+		return 0, err
+	}
+	if v%2 == 1 {
+		return int(v+1) / 2, nil
+	}
+	return -int(v / 2), nil
+}
+
+// unescapeRBSP убирает байты 0x03, защищающие от ложных стартовых кодов
+func unescapeRBSP(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// SPSInfo - профиль, уровень и размер кадра (с учётом обрезки) из sequence parameter set
+type SPSInfo struct {
+	Profile string
+	Level   string
+	Width   int
+	Height  int
+}
+
+var h264Profiles = map[uint]string{
+	66: "Baseline", 77: "Main", 88: "Extended", 100: "High", 110: "High 10",
+	122: "High 4:2:2", 244: "High 4:4:4 Predictive", 44: "CAVLC 4:4:4",
+}
+
+var hevcProfiles = map[uint]string{1: "Main", 2: "Main 10", 3: "Main Still Picture", 4: "Rext"}
+
+func profileName(names map[uint]string, idc uint) string {
+	if name, ok := names[idc]; ok {
+		return name
+	}
+	return fmt.Sprintf("profile %d", idc)
+}
+
+// parseH264SPS разбирает SPS H.264 (без байта заголовка NAL)
+func parseH264SPS(rbsp []byte) (*SPSInfo, error) {
+	r := &bitReader{data: unescapeRBSP(rbsp)}
+	profile, err := r.bits(8)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.skip(8); err != nil { // constraint flags
+		return nil, err
+	}
+	level, err := r.bits(8)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.ue(); err != nil { // seq_parameter_set_id
+		return nil, err
+	}
+
+	chromaFormat := uint(1)
+	separateColour := uint(0)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chromaFormat, err = r.ue(); err != nil {
+			return nil, err
+		}
+		if chromaFormat == 3 {
+			if separateColour, err = r.bit(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := r.ue(); err != nil { // bit_depth_luma_minus8
+			return nil, err
+		}
+		if _, err := r.ue(); err != nil { // bit_depth_chroma_minus8
+			return nil, err
+		}
+		if err := r.skip(1); err != nil { // qpprime_y_zero_transform_bypass_flag
+			return nil, err
+		}
+		scalingPresent, err := r.bit()
+		if err != nil {
+			return nil, err
+		}
+		if scalingPresent == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, err := r.bit()
+				if err != nil {
+					return nil, err
+				}
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err := skipScalingList(r, size); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if _, err := r.ue(); err != nil { // log2_max_frame_num_minus4
+		return nil, err
+	}
+	pocType, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	switch pocType {
+	case 0:
+		if _, err := r.ue(); err != nil {
+			return nil, err
+		}
+	case 1:
+		if err := r.skip(1); err != nil {
+			return nil, err
+		}
+		if _, err := r.se(); err != nil {
+			return nil, err
+		}
+		if _, err := r.se(); err != nil {
+			return nil, err
+		}
+		cycle, err := r.ue()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint(0); i < cycle; i++ {
+			if _, err := r.se(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := r.ue(); err != nil { // max_num_ref_frames
+		return nil, err
+	}
+	if err := r.skip(1); err != nil { // gaps_in_frame_num_value_allowed_flag
+		return nil, err
+	}
+
+	widthMbs, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	heightMapUnits, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return nil, err
+	}
+	if frameMbsOnly == 0 {
+		if err := r.skip(1); err != nil { // mb_adaptive_frame_field_flag
+			return nil, err
+		}
+	}
+	if err := r.skip(1); err != nil { // direct_8x8_inference_flag
+		return nil, err
+	}
+
+	width := int(widthMbs+1) * 16
+	height := int(2-frameMbsOnly) * int(heightMapUnits+1) * 16
+
+	cropping, err := r.bit()
+	if err != nil {
+		return nil, err
+	}
+	if cropping == 1 {
+		crop, err := readCrop(r)
+		if err != nil {
+			return nil, err
+		}
+		unitX, unitY := 1, int(2-frameMbsOnly)
+		if separateColour == 0 {
+			switch chromaFormat {
+			case 1:
+				unitX, unitY = 2, 2*int(2-frameMbsOnly)
+			case 2:
+				unitX, unitY = 2, int(2-frameMbsOnly)
+			}
+		}
+		width -= unitX * (crop[0] + crop[1])
+		height -= unitY * (crop[2] + crop[3])
+	}
+	return &SPSInfo{
+		Profile: profileName(h264Profiles, profile),
+		Level:   fmt.Sprintf("%.1f", float64(level)/10),
+		Width:   width,
+		Height:  height,
+	}, nil
+}
+
+func skipScalingList(r *bitReader, size int) error {
+	last, next := 8, 8
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
+
+// parseHEVCSPS разбирает SPS HEVC (без двух байт заголовка NAL)
+func parseHEVCSPS(rbsp []byte) (*SPSInfo, error) {
+	r := &bitReader{data: unescapeRBSP(rbsp)}
+	if err := r.skip(4); err != nil { // sps_video_parameter_set_id
+		return nil, err
+	}
+	maxSubLayers, err := r.bits(3)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.skip(1); err != nil { // sps_temporal_id_nesting_flag
+		return nil, err
+	}
+
+	// profile_tier_level: general_profile_space и tier, профиль, затем 80 бит флагов и level_idc
+	if err := r.skip(3); err != nil {
+		return nil, err
+	}
+	profile, err := r.bits(5)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.skip(80); err != nil {
+		return nil, err
+	}
+	level, err := r.bits(8)
+	if err != nil {
+		return nil, err
+	}
+	profilePresent := make([]uint, maxSubLayers)
+	levelPresent := make([]uint, maxSubLayers)
+	for i := range profilePresent {
+		if profilePresent[i], err = r.bit(); err != nil {
+			return nil, err
+		}
+		if levelPresent[i], err = r.bit(); err != nil {
+			return nil, err
+		}
+	}
+	if maxSubLayers > 0 {
+		if err := r.skip(2 * int(8-maxSubLayers)); err != nil {
+			return nil, err
+		}
+	}
+	for i := range profilePresent {
+		if profilePresent[i] == 1 {
+			if err := r.skip(88); err != nil {
+				return nil, err
+			}
+		}
+		if levelPresent[i] == 1 {
+			if err := r.skip(8); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := r.ue(); err != nil { // sps_seq_parameter_set_id
+		return nil, err
+	}
+	chromaFormat, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	if chromaFormat == 3 {
+		if err := r.skip(1); err != nil { // separate_colour_plane_flag
+			return nil, err
+		}
+	}
+	width, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	height, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	info := &SPSInfo{
+		Profile: profileName(hevcProfiles, profile),
+		Level:   fmt.Sprintf("%.1f", float64(level)/30),
+		Width:   int(width),
+		Height:  int(height),
+	}
+
+	window, err := r.bit()
+	if err != nil {
+		return nil, err
+	}
+	if window == 1 {
+		crop, err := readCrop(r)
+		if err != nil {
+			return nil, err
+		}
+		subWidth, subHeight := 1, 1
+		switch chromaFormat {
+		case 1:
+			subWidth, subHeight = 2, 2
+		case 2:
+			subWidth = 2
+		}
+		info.Width -= subWidth * (crop[0] + crop[1])
+		info.Height -= subHeight * (crop[2] + crop[3])
+	}
+	return info, nil
+}
+
+// readCrop читает отступы left, right, top, bottom
+func readCrop(r *bitReader) ([4]int, error) {
+	var crop [4]int
+	for i := range crop {
+		v, err := r.ue()
+		if err != nil {
+			return crop, err
+		}
+		crop[i] = int(v)
+	}
+	return crop, nil
+}
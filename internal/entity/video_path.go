@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"path"
+	"strings"
+)
+
+// CleanVideoPath проверяет путь папки видео относительно baseDir, например "shows/season1/ep1".
+// Пустые, абсолютные пути, ".." и скрытые компоненты (.meta, .mediafs.json) отклоняются.
+func CleanVideoPath(name string) (string, bool) {
+	if name == "" || strings.Contains(name, "\\") {
+		return "", false
+	}
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == "." {
+		return "", false
+	}
+	for _, part := range strings.Split(cleaned, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	return cleaned, true
+}
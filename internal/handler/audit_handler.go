@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+const (
+	defaultAuditLimit = 1000
+	maxAuditLimit     = 10000
+)
+
+// GetAuditLog - журнал аудита с фильтрами ?from=&to= (RFC3339), ?action=login,delete и ?limit=;
+// limit больше maxAuditLimit урезается, выгрузить весь журнал одним запросом нельзя
+func GetAuditLog(audit *service.AuditService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := service.AuditFilter{Limit: defaultAuditLimit}
+
+		var err error
+		if c.Query("limit") != "" {
+			if filter.Limit, err = queryNonNegative(c, "limit"); err != nil {
+				return err
+			}
+			if filter.Limit < 1 {
+				return fiber.NewError(fiber.StatusBadRequest, "limit must be positive")
+			}
+			filter.Limit = min(filter.Limit, maxAuditLimit)
+		}
+		if from := c.Query("from"); from != "" {
+			if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid from, want RFC3339")
+			}
+		}
+		if to := c.Query("to"); to != "" {
+			if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid to, want RFC3339")
+			}
+		}
+		for _, action := range strings.Split(c.Query("action"), ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, service.AuditAction(action))
+			}
+		}
+
+		events, err := audit.Query(filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(events)
+	}
+}
+
+// recordAudit пишет событие от имени текущего запроса; ошибка записи не должна ломать сам запрос
+func recordAudit(c *fiber.Ctx, audit *service.AuditService, action service.AuditAction, target, detail string) {
+	e := service.AuditEvent{
+		Action: action,
+		Actor:  middleware.PrincipalName(c),
+		IP:     c.IP(),
+		Target: target,
+		Detail: detail,
+	}
+	if session := middleware.CurrentSession(c); session != nil {
+		e.Session = session.ID
+	}
+	if err := audit.Record(e); err != nil {
+		log.Printf("❌ Failed to write audit log: %v", err)
+	}
+}
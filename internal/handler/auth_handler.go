@@ -1,24 +1,141 @@
 package handler
 
 import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
 	"mediafs/internal/service"
 )
 
-func AuthHandler(auth *service.AuthService) fiber.Handler {
+// AuthHandler проверяет пароль (и TOTP-код, если он включён) и открывает сессию. Частые неудачные попытки
+// блокируются limiter-ом с ответом 429 и заголовком Retry-After.
+func AuthHandler(auth *service.AuthService, limiter *service.LoginLimiter, audit *service.AuditService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		ip := c.IP()
+		if wait, ok := limiter.Allow(ip); !ok {
+			return tooManyAttempts(c, wait)
+		}
+		// попытка зарезервирована в Allow; если она не закончится ни Fail, ни Success, резерв снимается
+		settled := false
+		defer func() {
+			if !settled {
+				limiter.Release(ip)
+			}
+		}()
+
 		var req struct {
+			Username string `json:"username"`
 			Password string `json:"password"`
+			Device   string `json:"device"`
+			Code     string `json:"code"`
 		}
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
 		}
+		// Старые клиенты присылают только пароль
+		if req.Username == "" {
+			req.Username = service.DefaultUsername
+		}
+
+		if !auth.CheckPassword(req.Username, req.Password) {
+			recordLoginFailure(c, audit, req.Username, "invalid password")
+			settled = true
+			if wait := limiter.Fail(ip); wait > 0 {
+				return tooManyAttempts(c, wait)
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid username or password")
+		}
+
+		if auth.TOTPEnabled(req.Username) {
+			if req.Code == "" {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":        "two-factor code required",
+					"codeRequired": true,
+				})
+			}
+			if !auth.VerifySecondFactor(req.Username, req.Code) {
+				recordLoginFailure(c, audit, req.Username, "invalid two-factor code")
+				settled = true
+				if wait := limiter.Fail(ip); wait > 0 {
+					return tooManyAttempts(c, wait)
+				}
+				return fiber.NewError(fiber.StatusUnauthorized, "invalid two-factor code")
+			}
+		}
+		settled = true
+		limiter.Success(ip)
 
-		if !auth.CheckPassword(req.Password) {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid password")
+		pair, err := auth.CreateSession(req.Username, req.Device, c.Get(fiber.HeaderUserAgent))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 
-		token := auth.GenerateToken()
-		return c.JSON(fiber.Map{"token": token})
+		if err := audit.Record(service.AuditEvent{
+			Action:  service.AuditLogin,
+			Actor:   req.Username,
+			IP:      c.IP(),
+			Session: pair.SessionID,
+			Detail:  req.Device,
+		}); err != nil {
+			log.Printf("❌ Failed to write audit log: %v", err)
+		}
+		return c.JSON(pair)
+	}
+}
+
+func recordLoginFailure(c *fiber.Ctx, audit *service.AuditService, username, reason string) {
+	if err := audit.Record(service.AuditEvent{
+		Action: service.AuditLoginFailed,
+		Actor:  username,
+		IP:     c.IP(),
+		Detail: reason,
+	}); err != nil {
+		log.Printf("❌ Failed to write audit log: %v", err)
+	}
+}
+
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":      "too many failed login attempts",
+		"retryAfter": seconds,
+	})
+}
+
+// RefreshHandler выдаёт новую пару токенов по refresh-токену
+func RefreshHandler(auth *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+
+		pair, err := auth.Refresh(req.RefreshToken)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		return c.JSON(pair)
+	}
+}
+
+// LogoutHandler завершает сессию, с токеном которой пришёл запрос
+func LogoutHandler(auth *service.AuthService, audit *service.AuditService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		session := middleware.CurrentSession(c)
+		if session == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+		}
+		if err := auth.RevokeSession(session.ID, ""); err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		recordAudit(c, audit, service.AuditLogout, "", "")
+		return c.JSON(fiber.Map{"message": "logged out"})
 	}
 }
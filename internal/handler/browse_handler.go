@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/entity"
+	"mediafs/internal/service"
+)
+
+type BrowseFolder struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	BrowseURL string `json:"browseURL"`
+}
+
+// BrowseLevel - содержимое одного уровня библиотеки
+type BrowseLevel struct {
+	Path    string         `json:"path"`
+	Parent  *string        `json:"parent,omitempty"`
+	Folders []BrowseFolder `json:"folders"`
+	Videos  []MediaFile    `json:"videos"`
+}
+
+// Browse - вложенные папки и видео на одном уровне библиотеки: GET /browse/shows/season1
+func Browse(baseDir string, index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rel, err := url.PathUnescape(strings.Trim(c.Params("*"), "/"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid path")
+		}
+
+		level := BrowseLevel{
+			Folders: make([]BrowseFolder, 0),
+			Videos:  make([]MediaFile, 0),
+		}
+		if rel != "" {
+			cleaned, ok := entity.CleanVideoPath(rel)
+			if !ok {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid path"})
+			}
+			if index.IsVideo(cleaned) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "not a folder, use /videos/" + cleaned})
+			}
+			parent := path.Dir(cleaned)
+			if parent == "." {
+				parent = ""
+			}
+			level.Path, level.Parent = cleaned, &parent
+		}
+
+		entries, err := os.ReadDir(filepath.Join(baseDir, filepath.FromSlash(level.Path)))
+		if os.IsNotExist(err) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "folder not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			child := path.Join(level.Path, e.Name())
+			if entry, ok := index.Get(child); ok {
+				level.Videos = append(level.Videos, newMediaFile(baseDir, *entry))
+				continue
+			}
+			level.Folders = append(level.Folders, BrowseFolder{
+				Name:      e.Name(),
+				Path:      child,
+				BrowseURL: "/browse/" + child,
+			})
+		}
+		attachProgress(c, progress, level.Videos)
+
+		return c.JSON(level)
+	}
+}
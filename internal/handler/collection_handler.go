@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+type CollectionInfo struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Videos      []string `json:"videos"`
+	CoverURL    *string  `json:"coverURL,omitempty"`
+	PlaylistURL string   `json:"playlistURL"`
+	CreatedBy   string   `json:"createdBy"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+// CollectionDetail - коллекция вместе с карточками её видео; удалённые видео пропускаются
+type CollectionDetail struct {
+	CollectionInfo
+	Items []MediaFile `json:"items"`
+}
+
+func newCollectionInfo(col service.Collection) CollectionInfo {
+	info := CollectionInfo{
+		ID:          col.ID,
+		Name:        col.Name,
+		Description: col.Description,
+		Videos:      col.Videos,
+		PlaylistURL: "/collections/" + col.ID + "/playlist.m3u8",
+		CreatedBy:   col.CreatedBy,
+		CreatedAt:   col.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   col.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if col.Cover != nil {
+		url := "/keyframe/" + col.Cover.Video + "/" + col.Cover.Frame
+		info.CoverURL = &url
+	}
+	return info
+}
+
+func ListCollections(collections *service.CollectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list := collections.List()
+		result := make([]CollectionInfo, 0, len(list))
+		for _, col := range list {
+			result = append(result, newCollectionInfo(col))
+		}
+		return c.JSON(result)
+	}
+}
+
+func GetCollection(baseDir string, collections *service.CollectionService, index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		col, err := collections.Get(c.Params("id"))
+		if err != nil {
+			return collectionError(c, err)
+		}
+
+		items := make([]MediaFile, 0, len(col.Videos))
+		for _, videoID := range col.Videos {
+			folder, ok := index.Resolve(videoID)
+			if !ok {
+				continue
+			}
+			if entry, ok := index.Get(folder); ok {
+				items = append(items, newMediaFile(baseDir, *entry))
+			}
+		}
+		attachProgress(c, progress, items)
+
+		return c.JSON(CollectionDetail{
+			CollectionInfo: newCollectionInfo(*col),
+			Items:          items,
+		})
+	}
+}
+
+// CreateCollection создаёт коллекцию: {"name", "description", "videos": [...], "cover": {"video", "frame"}}
+func CreateCollection(collections *service.CollectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var patch service.CollectionPatch
+		if err := c.BodyParser(&patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+
+		col, err := collections.Create(patch, middleware.PrincipalName(c))
+		if err != nil {
+			return collectionError(c, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(newCollectionInfo(*col))
+	}
+}
+
+// UpdateCollection меняет поля коллекции; порядок видео задаётся полным списком videos
+func UpdateCollection(collections *service.CollectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var patch service.CollectionPatch
+		if err := c.BodyParser(&patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+
+		col, err := collections.Update(c.Params("id"), patch)
+		if err != nil {
+			return collectionError(c, err)
+		}
+		return c.JSON(newCollectionInfo(*col))
+	}
+}
+
+func DeleteCollection(collections *service.CollectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := collections.Delete(c.Params("id")); err != nil {
+			return collectionError(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "deleted"})
+	}
+}
+
+// CollectionPlaylist склеивает плейлисты видео коллекции в один VOD-плейлист с разрывами
+// между видео. Сегменты каждого видео подписаны на его папку, как в StreamHLSFile.
+func CollectionPlaylist(collections *service.CollectionService, signer *service.URLSigner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		parts, err := collections.Parts(c.Params("id"))
+		if err != nil {
+			return collectionError(c, err)
+		}
+
+		principal := middleware.PrincipalName(c)
+		sign := func(videoID string) string {
+			signature, _ := signer.Sign(principal, "/videos/"+videoID+"/")
+			return signature.Encode()
+		}
+
+		c.Response().Header.Set("Content-Type", "application/vnd.apple.mpegurl")
+		return c.SendString(chainPlaylist(parts, sign))
+	}
+}
+
+func chainPlaylist(parts []service.CollectionPart, sign func(videoID string) string) string {
+	target := 1.0
+	for _, part := range parts {
+		for _, seg := range part.Segments {
+			target = math.Max(target, seg.Duration)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target)))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		query := sign(part.VideoID)
+		for _, seg := range part.Segments {
+			uri := seg.URI
+			if !strings.Contains(uri, "://") && !strings.HasPrefix(uri, "/") {
+				uri = "/videos/" + part.VideoID + "/" + uri
+			}
+			fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", seg.Duration, appendQuery(uri, query))
+		}
+	}
+
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+func collectionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCollectionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCollection):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
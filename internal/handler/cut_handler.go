@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"mediafs/internal/service"
 )
@@ -11,9 +15,9 @@ type CutRequest struct {
 	Name string `json:"name"`
 }
 
-func CutHandler(cut *service.CutService) fiber.Handler {
+func CutHandler(cut *service.CutService, index *service.VideoIndex, audit *service.AuditService, events *service.EventBus) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		filename := c.Params("videoname")
+		filename := videoName(c)
 
 		var req CutRequest
 		if err := c.BodyParser(&req); err != nil {
@@ -24,14 +28,27 @@ func CutHandler(cut *service.CutService) fiber.Handler {
 		}
 
 		clipName, err := cut.CreateClip(filename, req.From, req.To, req.Name)
+		if errors.Is(err, service.ErrInvalidPath) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		if err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 
+		recordAudit(c, audit, service.AuditCut, filename, fmt.Sprintf("%s (segments %d-%d)", clipName, req.From, req.To))
+
+		url := "/videos/" + filename + "/" + clipName
+		// событие живёт дольше запроса, а filename ссылается на буфер fasthttp
+		event := fiber.Map{"folder": strings.Clone(filename), "file": clipName, "url": url, "from": req.From, "to": req.To}
+		if entry, ok := index.Get(filename); ok {
+			event["id"] = entry.ID
+		}
+		events.Publish(service.EventCutCreated, event)
+
 		return c.JSON(fiber.Map{
 			"message": "cut created",
 			"file":    clipName,
-			"url":     "/videos/" + filename + "/" + clipName,
+			"url":     url,
 		})
 	}
 }
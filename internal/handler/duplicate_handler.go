@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/service"
+)
+
+type DuplicateVideo struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	SegmentCount int    `json:"segmentCount"`
+	TotalBytes   int64  `json:"totalBytes"`
+}
+
+// DuplicateGroup - видео с одинаковыми (exact) или почти одинаковыми наборами сегментов;
+// reclaimableBytes - сколько освободится, если оставить одну копию
+type DuplicateGroup struct {
+	Exact            bool             `json:"exact"`
+	Similarity       float64          `json:"similarity"`
+	ReclaimableBytes int64            `json:"reclaimableBytes"`
+	Videos           []DuplicateVideo `json:"videos"`
+}
+
+type DuplicateReport struct {
+	Threshold float64          `json:"threshold"`
+	Groups    []DuplicateGroup `json:"groups"`
+}
+
+// ListDuplicates - GET /duplicates?threshold=0.9: группы повторно загруженных видео.
+// Первый запрос читает все сегменты библиотеки, следующие - только новые и изменённые.
+func ListDuplicates(index *service.VideoIndex, finder *service.DuplicateFinder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		threshold := service.DefaultDuplicateThreshold
+		if raw := c.Query("threshold"); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || !(value > 0 && value <= 1) {
+				return fiber.NewError(fiber.StatusBadRequest, service.ErrInvalidThreshold.Error())
+			}
+			threshold = value
+		}
+
+		entries, err := index.List()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		groups, err := finder.Find(entries, threshold)
+		if errors.Is(err, service.ErrInvalidThreshold) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		report := DuplicateReport{Threshold: threshold, Groups: make([]DuplicateGroup, 0, len(groups))}
+		for _, g := range groups {
+			group := DuplicateGroup{
+				Exact:            g.Exact,
+				Similarity:       g.Similarity,
+				ReclaimableBytes: g.Reclaimable,
+				Videos:           make([]DuplicateVideo, 0, len(g.Videos)),
+			}
+			for _, v := range g.Videos {
+				group.Videos = append(group.Videos, DuplicateVideo{
+					ID:           v.ID,
+					Name:         v.Folder,
+					SegmentCount: v.SegmentCount,
+					TotalBytes:   v.Bytes,
+				})
+			}
+			report.Groups = append(report.Groups, group)
+		}
+		return c.JSON(report)
+	}
+}
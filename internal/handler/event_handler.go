@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/service"
+)
+
+const eventHeartbeat = 15 * time.Second
+
+// StreamEvents - лента изменений библиотеки в формате Server-Sent Events: GET /events?types=video.added,cut.created.
+// После переподключения клиент получает пропущенные события по заголовку Last-Event-ID.
+func StreamEvents(events *service.EventBus) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var lastID uint64
+		if header := c.Get("Last-Event-ID"); header != "" {
+			id, err := strconv.ParseUint(header, 10, 64)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid Last-Event-ID")
+			}
+			lastID = id
+		}
+
+		types := map[service.EventType]bool{}
+		for _, t := range strings.Split(c.Query("types"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types[service.EventType(t)] = true
+			}
+		}
+		wanted := func(e service.Event) bool {
+			return len(types) == 0 || types[e.Type]
+		}
+
+		missed, ch, unsubscribe := events.Subscribe(lastID)
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		// c нельзя использовать внутри writer: запрос к этому моменту уже обработан
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			fmt.Fprint(w, "retry: 3000\n\n")
+			for _, e := range missed {
+				if wanted(e) {
+					writeEvent(w, e)
+				}
+			}
+			if w.Flush() != nil {
+				return
+			}
+
+			heartbeat := time.NewTicker(eventHeartbeat)
+			defer heartbeat.Stop()
+			for {
+				select {
+				case e, ok := <-ch:
+					if !ok {
+						return
+					}
+					if !wanted(e) {
+						continue
+					}
+					writeEvent(w, e)
+				case <-heartbeat.C:
+					fmt.Fprint(w, ": ping\n\n")
+				}
+				// ошибка записи означает, что клиент отключился
+				if w.Flush() != nil {
+					return
+				}
+			}
+		})
+		return nil
+	}
+}
+
+func writeEvent(w *bufio.Writer, e service.Event) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
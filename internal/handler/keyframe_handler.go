@@ -11,17 +11,22 @@ import (
 // GetKeyFrameFile - простой обработчик, возвращающий кадр по имени файла
 func GetKeyFrameFile(baseDir string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Получаем имя видео из URL
-		videoname := filepath.Base(c.Params("videoname"))
+		// Получаем папку видео, найденную ResolveVideo
+		videoname := videoName(c)
 
 		// Получаем имя файла из URL
-		filename := c.Params("filename")
+		filename := videoFile(c)
+		if filename == "" {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Keyframe not found",
+			})
+		}
 
 		// Защита от path traversal
 		filename = filepath.Base(filename)
 
 		// Формируем путь к файлу ключевого кадра
-		keyframePath := filepath.Join(baseDir, videoname, "keyframes", filename)
+		keyframePath := filepath.Join(baseDir, filepath.FromSlash(videoname), "keyframes", filename)
 
 		// Проверяем, существует ли файл
 		if _, err := os.Stat(keyframePath); os.IsNotExist(err) {
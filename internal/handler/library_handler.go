@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/service"
+)
+
+// LibraryStatus - режим наблюдения за библиотекой и итог последнего обхода
+type LibraryStatus struct {
+	Mode           string                `json:"mode"`
+	Watching       bool                  `json:"watching"`
+	WatchedDirs    int                   `json:"watchedDirs"`
+	Pending        []string              `json:"pending"`
+	LastScanAt     string                `json:"lastScanAt,omitempty"`
+	LastScanMs     int64                 `json:"lastScanMs"`
+	LastScanReason string                `json:"lastScanReason,omitempty"`
+	Videos         int                   `json:"videos"`
+	LastError      string                `json:"lastError,omitempty"`
+	RecentChanges  []service.VideoChange `json:"recentChanges"`
+}
+
+// GetLibraryStatus - GET /library/status
+func GetLibraryStatus(watcher *service.LibraryWatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		state := watcher.State()
+		status := LibraryStatus{
+			Mode:           state.Mode,
+			Watching:       state.Watching,
+			WatchedDirs:    state.WatchedDirs,
+			Pending:        state.Pending,
+			LastScanMs:     state.LastScanDuration.Milliseconds(),
+			LastScanReason: state.LastScanReason,
+			Videos:         state.Videos,
+			LastError:      state.LastError,
+			RecentChanges:  state.RecentChanges,
+		}
+		if !state.LastScanAt.IsZero() {
+			status.LastScanAt = state.LastScanAt.Format(time.RFC3339)
+		}
+		return c.JSON(status)
+	}
+}
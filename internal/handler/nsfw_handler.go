@@ -12,7 +12,7 @@ import (
 func GetNsfwFrameList(baseDir string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Получаем безопасное имя видеопапки
-		videoname := filepath.Base(c.Params("videoname"))
+		videoname := videoName(c)
 
 		// Путь к папке nsfw
 		nsfwDir := filepath.Join(baseDir, videoname, "nsfw")
@@ -44,20 +44,25 @@ func GetNsfwFrameList(baseDir string) fiber.Handler {
 	}
 }
 
-// GetKeyFrameFile - простой обработчик, возвращающий кадр по имени файла
+// GetNsfwFrameFile - возвращает кадр по имени файла; без имени файла отдаёт список кадров,
+// чтобы /nsfw/<вложенная/папка>/ работал так же, как /nsfw/<папка>
 func GetNsfwFrameFile(baseDir string) fiber.Handler {
+	list := GetNsfwFrameList(baseDir)
 	return func(c *fiber.Ctx) error {
-		// Получаем имя видео из URL
-		videoname := filepath.Base(c.Params("videoname"))
+		// Получаем папку видео, найденную ResolveVideo
+		videoname := videoName(c)
 
 		// Получаем имя файла из URL
-		filename := c.Params("filename")
+		filename := videoFile(c)
+		if filename == "" {
+			return list(c)
+		}
 
 		// Защита от path traversal
 		filename = filepath.Base(filename)
 
 		// Формируем путь к файлу ключевого кадра
-		keyframePath := filepath.Join(baseDir, videoname, "nsfw", filename)
+		keyframePath := filepath.Join(baseDir, filepath.FromSlash(videoname), "nsfw", filename)
 
 		// Проверяем, существует ли файл
 		if _, err := os.Stat(keyframePath); os.IsNotExist(err) {
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+const defaultContinueLimit = 20
+
+// VideoProgress - сохранённое место просмотра текущего пользователя
+type VideoProgress struct {
+	Position  float64 `json:"position"`
+	Finished  bool    `json:"finished"`
+	Device    string  `json:"device,omitempty"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+type ProgressRequest struct {
+	Position *float64 `json:"position"`
+	Finished bool     `json:"finished"`
+}
+
+func newVideoProgress(p service.WatchProgress) *VideoProgress {
+	return &VideoProgress{
+		Position:  p.Position,
+		Finished:  p.Finished,
+		Device:    p.Device,
+		UpdatedAt: p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// attachProgress дописывает в карточки видео прогресс пользователя, от имени которого пришёл запрос
+func attachProgress(c *fiber.Ctx, progress *service.ProgressService, files []MediaFile) {
+	all := progress.All(middleware.PrincipalName(c))
+	if len(all) == 0 {
+		return
+	}
+	for i := range files {
+		if p, ok := all[files[i].ID]; ok {
+			files[i].Progress = newVideoProgress(p)
+		}
+	}
+}
+
+// PutProgress сохраняет место просмотра: PUT /videos/:videoname/progress {"position": 754.2, "finished": false}
+func PutProgress(index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req ProgressRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+		if req.Position == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "position is required")
+		}
+
+		entry, ok := index.Get(videoName(c))
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "video not found"})
+		}
+
+		principal := middleware.PrincipalName(c)
+		if principal == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "user required"})
+		}
+		saved, err := progress.Save(principal, entry.ID, *req.Position, req.Finished, middleware.CurrentSession(c))
+		if errors.Is(err, service.ErrInvalidProgress) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(newVideoProgress(*saved))
+	}
+}
+
+// ContinueWatching - недосмотренные видео пользователя, последние просмотренные первыми: GET /continue-watching?limit=
+func ContinueWatching(baseDir string, index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit, err := queryLimit(c, defaultContinueLimit, maxPageLimit)
+		if err != nil {
+			return err
+		}
+
+		recent := progress.Recent(middleware.PrincipalName(c))
+		if len(recent) == 0 {
+			return c.JSON([]MediaFile{})
+		}
+		// один обход библиотеки на запрос: искать каждое видео по ID отдельно дорого,
+		// а для удалённых видео Resolve пересобирал бы индекс целиком
+		entries, err := index.List()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		byID := make(map[string]service.VideoEntry, len(entries))
+		for _, e := range entries {
+			byID[e.ID] = e
+		}
+
+		items := make([]MediaFile, 0)
+		for _, p := range recent {
+			if len(items) == limit {
+				break
+			}
+			// удалённые видео пропускаем, прогресс по ним остаётся на случай восстановления
+			entry, ok := byID[p.VideoID]
+			if !ok {
+				continue
+			}
+			file := newMediaFile(baseDir, entry)
+			file.Progress = newVideoProgress(p)
+			items = append(items, file)
+		}
+		return c.JSON(items)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+type SessionInfo struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Device    string `json:"device,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	LastSeen  string `json:"lastSeen"`
+	ExpiresAt string `json:"expiresAt"`
+	Current   bool   `json:"current"`
+}
+
+// ListSessions - устройства текущего пользователя; администратор с ?all=true видит все сессии
+func ListSessions(auth *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := middleware.CurrentUser(c)
+		current := middleware.CurrentSession(c)
+
+		username := user.Username
+		if c.QueryBool("all") && user.Role.Allows(service.RoleAdmin) {
+			username = ""
+		}
+
+		sessions := auth.Sessions(username)
+		result := make([]SessionInfo, 0, len(sessions))
+		for _, s := range sessions {
+			result = append(result, SessionInfo{
+				ID:        s.ID,
+				Username:  s.Username,
+				Device:    s.Device,
+				UserAgent: s.UserAgent,
+				CreatedAt: s.CreatedAt.UTC().Format(time.RFC3339),
+				LastSeen:  s.LastSeen.UTC().Format(time.RFC3339),
+				ExpiresAt: s.RefreshExpiresAt.UTC().Format(time.RFC3339),
+				Current:   current != nil && current.ID == s.ID,
+			})
+		}
+		return c.JSON(result)
+	}
+}
+
+// RevokeSession завершает сессию по ID; чужие сессии может завершать только администратор
+func RevokeSession(auth *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := middleware.CurrentUser(c)
+
+		owner := user.Username
+		if user.Role.Allows(service.RoleAdmin) {
+			owner = ""
+		}
+
+		err := auth.RevokeSession(c.Params("id"), owner)
+		if errors.Is(err, service.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "revoked"})
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+// sharePrincipal - субъект подписи, которую получает владелец запароленной ссылки;
+// она открывает только плейлист
+const sharePrincipal = "share"
+
+// shareViewPrincipal - субъект подписи, которую получает засчитанный просмотр: плейлист
+// выдаётся с ней, и только она открывает сегменты, спрайты и ключевые кадры
+const shareViewPrincipal = "share-view"
+
+// ShareInfo - ссылка на видео. Token и URL есть только в ответе на создание:
+// сервер хранит лишь хэш токена.
+type ShareInfo struct {
+	ID          string `json:"id"`
+	Token       string `json:"token,omitempty"`
+	VideoID     string `json:"videoId"`
+	Video       string `json:"video"`
+	URL         string `json:"url,omitempty"`
+	CreatedBy   string `json:"createdBy"`
+	CreatedAt   string `json:"createdAt"`
+	ExpiresAt   string `json:"expiresAt"`
+	MaxViews    int    `json:"maxViews,omitempty"`
+	Views       int    `json:"views"`
+	HasPassword bool   `json:"hasPassword"`
+}
+
+func newShareInfo(s service.Share, folder string) ShareInfo {
+	return ShareInfo{
+		ID:          s.ID,
+		VideoID:     s.VideoID,
+		Video:       folder,
+		CreatedBy:   s.CreatedBy,
+		CreatedAt:   s.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:   s.ExpiresAt.UTC().Format(time.RFC3339),
+		MaxViews:    s.MaxViews,
+		Views:       s.Views,
+		HasPassword: s.HasPassword(),
+	}
+}
+
+// CreateShare создаёт анонимную ссылку на видео: срок в секундах, лимит просмотров и пароль необязательны
+func CreateShare(shares *service.ShareService, index *service.VideoIndex, audit *service.AuditService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		videoname := videoName(c)
+		entry, ok := index.Get(videoname)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "video not found"})
+		}
+
+		var req struct {
+			ExpiresIn int    `json:"expiresIn"`
+			MaxViews  int    `json:"maxViews"`
+			Password  string `json:"password"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+		if req.ExpiresIn < 0 || req.MaxViews < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "expiresIn and maxViews must not be negative")
+		}
+
+		ttl := service.DefaultShareTTL
+		if req.ExpiresIn > 0 {
+			ttl = time.Duration(req.ExpiresIn) * time.Second
+		}
+
+		token, share, err := shares.Create(entry.ID, middleware.PrincipalName(c), ttl, req.MaxViews, req.Password)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		recordAudit(c, audit, service.AuditShareCreate, videoname, share.ID)
+		info := newShareInfo(*share, videoname)
+		info.Token = token
+		info.URL = "/s/" + token + "/playlist.m3u8"
+		return c.Status(fiber.StatusCreated).JSON(info)
+	}
+}
+
+func ListShares(shares *service.ShareService, index *service.VideoIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		videoname := videoName(c)
+		entry, ok := index.Get(videoname)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "video not found"})
+		}
+
+		list := shares.List(entry.ID)
+		result := make([]ShareInfo, 0, len(list))
+		for _, s := range list {
+			result = append(result, newShareInfo(s, videoname))
+		}
+		return c.JSON(result)
+	}
+}
+
+func DeleteShare(shares *service.ShareService, index *service.VideoIndex, audit *service.AuditService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		videoname := videoName(c)
+		entry, ok := index.Get(videoname)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "video not found"})
+		}
+
+		err := shares.Delete(entry.ID, c.Params("id"))
+		if errors.Is(err, service.ErrShareNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "share not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		recordAudit(c, audit, service.AuditShareDelete, videoname, c.Params("id"))
+		return c.JSON(fiber.Map{"message": "deleted"})
+	}
+}
+
+// UnlockShare меняет пароль ссылки на подписанный адрес плейлиста, который можно отдать плееру.
+// Подбор пароля ограничивается limiter-ом и по IP, и по самой ссылке (её открытому ID),
+// так же как вход в AuthHandler.
+func UnlockShare(shares *service.ShareService, signer *service.URLSigner, limiter *service.LoginLimiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Params("token")
+		share, err := shares.Get(token)
+		if err != nil {
+			return shareError(c, err)
+		}
+
+		ip, key := c.IP(), "share:"+share.ID
+		if wait, ok := limiter.Allow(ip); !ok {
+			return tooManyAttempts(c, wait)
+		}
+		if wait, ok := limiter.Allow(key); !ok {
+			limiter.Release(ip)
+			return tooManyAttempts(c, wait)
+		}
+		settled := false
+		defer func() {
+			if !settled {
+				limiter.Release(ip)
+				limiter.Release(key)
+			}
+		}()
+
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+		settled = true
+		if !shares.CheckPassword(token, req.Password) {
+			wait := max(limiter.Fail(ip), limiter.Fail(key))
+			if wait > 0 {
+				return tooManyAttempts(c, wait)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid password"})
+		}
+		limiter.Success(ip)
+		limiter.Success(key)
+
+		signature, expires := signer.Sign(sharePrincipal, "/s/"+token+"/")
+		return c.JSON(fiber.Map{
+			"url":       "/s/" + token + "/playlist.m3u8?" + signature.Encode(),
+			"expiresAt": expires.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// StreamShare - публичная раздача одного видео по ссылке: плейлист, сегменты, спрайты и ключевые кадры.
+// Каждая загрузка плейлиста засчитывается в maxViews, а ссылки в нём подписываются на этот
+// просмотр; без такой подписи остальные файлы не отдаются, так что лимит не обойти, запрашивая
+// сегменты напрямую.
+func StreamShare(baseDir string, shares *service.ShareService, signer *service.URLSigner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Params("token")
+		share, err := shares.Get(token)
+		if err != nil {
+			return shareError(c, err)
+		}
+
+		relativePath := filepath.ToSlash(filepath.Clean(c.Params("*")))
+		if !shareablePath(relativePath) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "not available via share link"})
+		}
+
+		principal, signed := signer.Verify(c.Path(),
+			c.Query(service.SignParamUser),
+			c.Query(service.SignParamScope),
+			c.Query(service.SignParamExpires),
+			c.Query(service.SignParamSig))
+
+		query := ""
+		if relativePath == "playlist.m3u8" {
+			if share.HasPassword() && (!signed || principal != sharePrincipal) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "password required", "passwordRequired": true})
+			}
+			if err := shares.RecordView(token); err != nil {
+				return shareError(c, err)
+			}
+			view, _ := signer.Sign(shareViewPrincipal, "/s/"+token+"/")
+			query = view.Encode()
+		} else if !signed || principal != shareViewPrincipal {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "open the share playlist first"})
+		}
+
+		folder, ok := shares.Folder(share)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "video not found"})
+		}
+		return sendVideoFile(c, baseDir, folder, relativePath, query)
+	}
+}
+
+// shareablePath ограничивает ссылку основным плейлистом, сегментами, спрайтами и ключевыми кадрами
+func shareablePath(relativePath string) bool {
+	if relativePath == "playlist.m3u8" {
+		return true
+	}
+	if strings.HasPrefix(relativePath, "sprites/") || strings.HasPrefix(relativePath, "keyframes/") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(relativePath)) {
+	case ".ts", ".m4s", ".aac":
+		return true
+	}
+	return false
+}
+
+func shareError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrShareNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrShareExpired), errors.Is(err, service.ErrShareExhausted):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
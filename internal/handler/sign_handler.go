@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+)
+
+// uriAttr - атрибут URI="..." в тегах EXT-X-KEY, EXT-X-MAP, EXT-X-MEDIA и т.п.
+var uriAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+// SignURLHandler выдаёт подписанную ссылку на файл видео или на плейлист подборки,
+// которую можно отдать плееру как есть
+func SignURLHandler(signer *service.URLSigner, index *service.VideoIndex, collections *service.CollectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+
+		// Подпись открывает ровно одну папку видео: /videos/<папка или ID>/, или плейлист
+		// подборки: сегменты в нём CollectionPlaylist подписывает на папки видео сам
+		scope, ok := videoScope(index, req.Path)
+		if !ok {
+			scope, ok = collectionScope(collections, req.Path)
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusBadRequest, "path must point to a file inside /videos/<name>/ or to /collections/<id>/playlist.m3u8")
+		}
+
+		signature, expires := signer.Sign(middleware.PrincipalName(c), scope)
+		return c.JSON(fiber.Map{
+			"url":       req.Path + "?" + signature.Encode(),
+			"expiresAt": expires.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// videoScope находит в пути /videos/... папку видео так же, как ResolveVideo: вложенная
+// папка - самый короткий префикс с плейлистом. Возвращает префикс URL до файла включая "/".
+func videoScope(index *service.VideoIndex, p string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, "/videos/")
+	if !ok || path.Clean(p) != p {
+		return "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[len(parts)-1] == "" {
+		return "", false
+	}
+	if first, err := url.PathUnescape(parts[0]); err != nil || hasDotSegment(first) || !validRestPath(strings.Join(parts[1:], "/")) {
+		return "", false
+	}
+
+	folder := ""
+	for i, part := range parts[:len(parts)-1] {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			part = unescaped
+		}
+		folder = strings.TrimPrefix(folder+"/"+part, "/")
+		if index.IsVideo(folder) {
+			return "/videos/" + strings.Join(parts[:i+1], "/") + "/", true
+		}
+	}
+	// ID или экранированный путь папки в первом сегменте
+	name, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return "", false
+	}
+	if _, ok := index.Resolve(name); !ok {
+		return "", false
+	}
+	return "/videos/" + parts[0] + "/", true
+}
+
+// collectionScope принимает только /collections/<id>/playlist.m3u8 существующей подборки
+func collectionScope(collections *service.CollectionService, p string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, "/collections/")
+	if !ok {
+		return "", false
+	}
+	id, file, _ := strings.Cut(rest, "/")
+	if file != "playlist.m3u8" {
+		return "", false
+	}
+	if _, err := collections.Get(id); err != nil {
+		return "", false
+	}
+	return "/collections/" + id + "/", true
+}
+
+// signPlaylist добавляет query к каждой относительной ссылке плейлиста
+func signPlaylist(data []byte, query string) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "#"):
+			lines[i] = uriAttr.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := uriAttr.FindStringSubmatch(attr)[1]
+				return `URI="` + appendQuery(uri, query) + `"`
+			})
+		default:
+			lines[i] = appendQuery(trimmed, query)
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func appendQuery(uri, query string) string {
+	if uri == "" || strings.Contains(uri, "://") || strings.HasPrefix(uri, "data:") {
+		return uri
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query
+	}
+	return uri + "?" + query
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/service"
+)
+
+// VideoUsage - место, занятое одним видео, по видам файлов в байтах
+type VideoUsage struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Duration   int              `json:"duration"`
+	TotalBytes int64            `json:"totalBytes"`
+	ByKind     map[string]int64 `json:"byKind"`
+}
+
+type DiskStats struct {
+	TotalBytes     uint64 `json:"totalBytes"`
+	UsedBytes      uint64 `json:"usedBytes"`
+	FreeBytes      uint64 `json:"freeBytes"`
+	AvailableBytes uint64 `json:"availableBytes"`
+}
+
+// LibraryStats - итог по библиотеке; видео отсортированы по занятому месту
+type LibraryStats struct {
+	VideoCount    int              `json:"videoCount"`
+	TotalBytes    int64            `json:"totalBytes"`
+	TotalDuration int              `json:"totalDuration"`
+	ByKind        map[string]int64 `json:"byKind"`
+	ByResolution  map[string]int   `json:"byResolution"`
+	Disk          *DiskStats       `json:"disk"`
+	Videos        []VideoUsage     `json:"videos"`
+}
+
+// GetStats - GET /stats: размер библиотеки по видам файлов (сегменты, ключевые кадры, спрайты,
+// превью, клипы...), общая длительность, число видео по разрешениям и место на диске
+func GetStats(baseDir string, index *service.VideoIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entries, err := index.List()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		stats := LibraryStats{
+			VideoCount:   len(entries),
+			ByKind:       map[string]int64{},
+			ByResolution: map[string]int{},
+			Videos:       make([]VideoUsage, 0, len(entries)),
+		}
+		for _, entry := range entries {
+			usage, err := videoUsage(baseDir, entry)
+			if err != nil {
+				// папку могли удалить между обходом индекса и подсчётом
+				log.Printf("❌ Failed to measure %s: %v", entry.Folder, err)
+				continue
+			}
+			for kind, size := range usage.ByKind {
+				stats.ByKind[kind] += size
+			}
+			stats.TotalBytes += usage.TotalBytes
+			stats.TotalDuration += entry.Duration
+
+			resolution := entry.Resolution
+			if resolution == "" {
+				resolution = "unknown"
+			}
+			stats.ByResolution[resolution]++
+			stats.Videos = append(stats.Videos, *usage)
+		}
+		sort.SliceStable(stats.Videos, func(i, j int) bool {
+			return stats.Videos[i].TotalBytes > stats.Videos[j].TotalBytes
+		})
+
+		if disk, err := service.GetDiskUsage(baseDir); err == nil {
+			stats.Disk = &DiskStats{
+				TotalBytes:     disk.Total,
+				UsedBytes:      disk.Used,
+				FreeBytes:      disk.Free,
+				AvailableBytes: disk.Available,
+			}
+		} else {
+			log.Printf("❌ Failed to read disk usage: %v", err)
+		}
+
+		return c.JSON(stats)
+	}
+}
+
+// videoUsage суммирует все файлы папки видео, включая сегменты, которые listVideoAssets пропускает
+func videoUsage(baseDir string, entry service.VideoEntry) (*VideoUsage, error) {
+	usage := &VideoUsage{
+		ID:       entry.ID,
+		Name:     entry.Folder,
+		Duration: entry.Duration,
+		ByKind:   map[string]int64{},
+	}
+
+	root := filepath.Join(baseDir, filepath.FromSlash(entry.Folder))
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		kind := usageKind(filepath.ToSlash(rel))
+		usage.ByKind[kind] += info.Size()
+		usage.TotalBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// usageKind - вид файла для статистики: как assetKind, но сегменты и клипы считаются отдельно
+func usageKind(rel string) string {
+	switch kind := assetKind(rel); {
+	case kind == "":
+		return "segment"
+	case kind == "playlist" && rel != "playlist.m3u8":
+		return "cut"
+	default:
+		return kind
+	}
+}
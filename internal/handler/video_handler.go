@@ -1,126 +1,583 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"mediafs/internal/entity"
+	"mediafs/internal/middleware"
+	"mediafs/internal/service"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type MediaFile struct {
-	ID                 string  `json:"id"`
-	Name               string  `json:"name"`
-	HLSURL             string  `json:"hlsURL"`
-	KeyframesURL       *string `json:"keyframesURL,omitempty"`
-	NsfwframesURL      *string `json:"nsfwframesURL,omitempty"`
-	CreatedAt          string  `json:"createdAt,omitempty"`
-	Duration           int     `json:"duration"`
-	Resolution         string  `json:"resolution,omitempty"`
-	SizeMB             int     `json:"sizeMB,omitempty"`
-	SegmentCount       int     `json:"segmentCount"`
-	AvgSegmentDuration float64 `json:"avgSegmentDuration"`
-}
-
-func ListVideos(baseDir string) fiber.Handler {
+	ID                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Title              string            `json:"title,omitempty"`
+	Description        string            `json:"description,omitempty"`
+	Tags               []string          `json:"tags,omitempty"`
+	Fields             map[string]string `json:"fields,omitempty"`
+	HLSURL             string            `json:"hlsURL"`
+	KeyframesURL       *string           `json:"keyframesURL,omitempty"`
+	NsfwframesURL      *string           `json:"nsfwframesURL,omitempty"`
+	CreatedAt          string            `json:"createdAt,omitempty"`
+	Duration           int               `json:"duration"`
+	Resolution         string            `json:"resolution,omitempty"`
+	SizeMB             int               `json:"sizeMB,omitempty"`
+	SegmentCount       int               `json:"segmentCount"`
+	AvgSegmentDuration float64           `json:"avgSegmentDuration"`
+	Streams            *VideoStreams     `json:"streams,omitempty"`
+	Progress           *VideoProgress    `json:"progress,omitempty"`
+}
+
+// VideoStreams - кодеки, частота кадров, битрейт и дорожки видео
+type VideoStreams struct {
+	VideoCodec      string       `json:"videoCodec,omitempty"`
+	VideoProfile    string       `json:"videoProfile,omitempty"`
+	VideoLevel      string       `json:"videoLevel,omitempty"`
+	FrameRate       float64      `json:"frameRate,omitempty"`
+	Bitrate         int          `json:"bitrate,omitempty"`
+	AudioCodec      string       `json:"audioCodec,omitempty"`
+	AudioChannels   int          `json:"audioChannels,omitempty"`
+	AudioSampleRate int          `json:"audioSampleRate,omitempty"`
+	AudioTracks     []VideoTrack `json:"audioTracks"`
+	SubtitleTracks  []VideoTrack `json:"subtitleTracks"`
+}
+
+type VideoTrack struct {
+	PID        int    `json:"pid"`
+	Codec      string `json:"codec"`
+	Language   string `json:"language,omitempty"`
+	Profile    string `json:"profile,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	SampleRate int    `json:"sampleRate,omitempty"`
+}
+
+func newVideoStreams(info *entity.StreamInfo) *VideoStreams {
+	if info == nil {
+		return nil
+	}
+	return &VideoStreams{
+		VideoCodec:      info.VideoCodec,
+		VideoProfile:    info.VideoProfile,
+		VideoLevel:      info.VideoLevel,
+		FrameRate:       info.FrameRate,
+		Bitrate:         info.Bitrate,
+		AudioCodec:      info.AudioCodec,
+		AudioChannels:   info.AudioChannels,
+		AudioSampleRate: info.AudioSampleRate,
+		AudioTracks:     newVideoTracks(info.AudioTracks),
+		SubtitleTracks:  newVideoTracks(info.SubtitleTracks),
+	}
+}
+
+func newVideoTracks(tracks []entity.TrackInfo) []VideoTrack {
+	result := make([]VideoTrack, 0, len(tracks))
+	for _, t := range tracks {
+		result = append(result, VideoTrack{
+			PID:        t.PID,
+			Codec:      t.Codec,
+			Language:   t.Language,
+			Profile:    t.Profile,
+			Channels:   t.Channels,
+			SampleRate: t.SampleRate,
+		})
+	}
+	return result
+}
+
+// ListVideos отдаёт страницу видео из индекса метаданных; сегменты разбираются только для новых или изменённых папок.
+// Параметры фильтрации, сортировки и пагинации описаны в parseVideoQuery.
+func ListVideos(baseDir string, index *service.VideoIndex, journal *service.ChangeJournal, progress *service.ProgressService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		entries, err := os.ReadDir(baseDir)
+		query, err := parseVideoQuery(c)
+		if err != nil {
+			return err
+		}
+
+		// токен берётся до обхода: изменение во время обхода клиент получит ещё раз, но не потеряет
+		token := journal.Token()
+		entries, err := index.List()
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
 
-		files := make([]MediaFile, 0)
-
+		files := make([]MediaFile, 0, len(entries))
 		for _, entry := range entries {
-			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
-				continue
+			file := newMediaFile(baseDir, entry)
+			if query.streams {
+				file.Streams = newVideoStreams(entry.Streams)
 			}
+			files = append(files, file)
+		}
+		attachProgress(c, progress, files)
+
+		page := query.apply(files)
+		page.ChangeToken = token
+		return c.JSON(page)
+	}
+}
 
-			folderName := entry.Name()
-			info := entity.NewMediaInfo(baseDir, folderName)
+// VideoChanges - постоянные ID видео, изменившихся после токена, и токен для следующего запроса
+type VideoChanges struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
+	Token   string   `json:"token"`
+	HasMore bool     `json:"hasMore"`
+}
 
-			playlist := info.Playlist()
-			if playlist == nil {
-				continue
-			}
+// ListVideoChanges - GET /videos/changes?since=<token>&limit=. Без since возвращает только текущий токен.
+// Если журнал уже сжат дальше токена, отвечает 410 и клиент заново загружает GET /videos.
+func ListVideoChanges(journal *service.ChangeJournal) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		since := c.Query("since")
+		if since == "" {
+			return c.JSON(VideoChanges{Added: []string{}, Updated: []string{}, Deleted: []string{}, Token: journal.Token()})
+		}
 
-			files = append(files, MediaFile{
-				ID:                 info.ID(),
-				Name:               folderName,
-				HLSURL:             info.StreamURL(),
-				KeyframesURL:       info.KeyFramesURL(),
-				NsfwframesURL:      info.NsfwFramesURL(),
-				CreatedAt:          info.CreatedAt(),
-				Duration:           playlist.Duration(),
-				Resolution:         playlist.Resolution(),
-				SizeMB:             playlist.SizeMB(),
-				SegmentCount:       playlist.SegmentCount(),
-				AvgSegmentDuration: playlist.AvgSegmentDuration(),
+		limit, err := queryLimit(c, maxPageLimit, maxPageLimit)
+		if err != nil {
+			return err
+		}
+
+		set, err := journal.Since(since, limit)
+		switch {
+		case errors.Is(err, service.ErrInvalidChangeToken):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrChangeTokenExpired):
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{
+				"error":  err.Error(),
+				"resync": true,
+				"token":  journal.Token(),
 			})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
 
-		return c.JSON(files)
+		return c.JSON(VideoChanges{
+			Added:   set.Added,
+			Updated: set.Updated,
+			Deleted: set.Deleted,
+			Token:   set.Token,
+			HasMore: set.More,
+		})
+	}
+}
+
+func newMediaFile(baseDir string, entry service.VideoEntry) MediaFile {
+	info := entity.NewMediaInfo(baseDir, entry.Folder)
+	return MediaFile{
+		ID:                 entry.ID,
+		Name:               entry.Folder,
+		Title:              entry.Meta.Title,
+		Description:        entry.Meta.Description,
+		Tags:               entry.Meta.Tags,
+		Fields:             entry.Meta.Fields,
+		HLSURL:             info.StreamURL(),
+		KeyframesURL:       info.KeyFramesURL(),
+		NsfwframesURL:      info.NsfwFramesURL(),
+		CreatedAt:          entry.CreatedAt.UTC().Format(time.RFC3339),
+		Duration:           entry.Duration,
+		Resolution:         entry.Resolution,
+		SizeMB:             entry.SizeMB,
+		SegmentCount:       entry.SegmentCount,
+		AvgSegmentDuration: entry.AvgSegmentDuration,
 	}
 }
 
-// StreamHLSFile - теперь умеет правильно ставить Content-Type для mp4, jpg, vtt
-func StreamHLSFile(baseDir string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		videoname := filepath.Base(c.Params("videoname"))
-		relativePath := filepath.Clean(c.Params("*")) // <-- относительный путь внутри видео папки
+// VideoAsset - производный файл видео: плейлист, кадр, спрайт, превью и т.п.
+type VideoAsset struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	SizeKB int64  `json:"sizeKB"`
+}
 
-		fullDir := filepath.Join(baseDir, videoname)
-		fullPath := filepath.Join(fullDir, relativePath)
+type VideoDetail struct {
+	MediaFile
+	Assets []VideoAsset `json:"assets"`
+}
+
+// Ключи Locals, под которыми ResolveVideo сохраняет найденное видео
+const (
+	videoNameKey   = "videoname"
+	videoFileKey   = "videofile"
+	videoPrefixKey = "videoprefix"
+)
+
+// ResolveVideo находит видео по :videoname: это ID, путь папки (вложенный - в экранированном
+// виде, shows%2Fs1%2Fep1) или, на маршрутах с "*", начало неэкранированного пути:
+// /videos/shows/s1/ep1/playlist.m3u8. В последнем случае папкой считается самый короткий
+// префикс, в котором есть плейлист, а остаток пути - файлом внутри неё.
+// Сегменты "." и ".." и экранированные разделители в остатке пути не принимаются.
+func ResolveVideo(index *service.VideoIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("videoname")
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		rest := c.Params("*")
+		if hasDotSegment(name) || !validRestPath(rest) {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid path")
+		}
 
-		if !strings.HasPrefix(fullPath, fullDir) {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "invalid path",
+		folder, ok := name, index.IsVideo(name)
+		if !ok {
+			// ID совпал - дальше путь не разбираем, остаток целиком относится к файлу
+			if byID, found := index.FindID(name); found {
+				folder, ok = byID, true
+			}
+		}
+		for !ok && rest != "" {
+			next, tail, _ := strings.Cut(rest, "/")
+			if unescaped, err := url.PathUnescape(next); err == nil {
+				next = unescaped
+			}
+			folder, rest = folder+"/"+next, tail
+			ok = index.IsVideo(folder)
+		}
+		if ok {
+			folder, _ = entity.CleanVideoPath(folder)
+		} else {
+			rest = c.Params("*")
+			folder, ok = index.Resolve(name)
+		}
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "video not found",
 			})
 		}
 
-		if _, err := os.Stat(fullPath); err != nil {
+		file, err := url.PathUnescape(rest)
+		if err != nil {
+			file = rest
+		}
+		c.Locals(videoNameKey, folder)
+		c.Locals(videoFileKey, file)
+		c.Locals(videoPrefixKey, strings.TrimSuffix(c.Path(), rest))
+		return c.Next()
+	}
+}
+
+// hasDotSegment - в пути есть сегмент "." или ".."
+func hasDotSegment(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if part == "." || part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// validRestPath проверяет неэкранированный остаток URL после :videoname: каждый сегмент
+// после раскодирования не должен быть "."/".." и не должен содержать разделителей
+func validRestPath(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	for _, part := range strings.Split(rest, "/") {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil || unescaped == "." || unescaped == ".." || strings.ContainsAny(unescaped, "/\\") {
+			return false
+		}
+	}
+	return true
+}
+
+// videoName возвращает папку видео, найденную ResolveVideo
+func videoName(c *fiber.Ctx) string {
+	if folder, ok := c.Locals(videoNameKey).(string); ok {
+		return folder
+	}
+	return filepath.Base(c.Params("videoname"))
+}
+
+// videoFile возвращает путь файла внутри папки видео (часть URL после неё)
+func videoFile(c *fiber.Ctx) string {
+	if file, ok := c.Locals(videoFileKey).(string); ok {
+		return file
+	}
+	return c.Params("*")
+}
+
+// GetVideo - карточка одного видео со списком производных файлов; сегменты не перечисляются
+func GetVideo(baseDir string, index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entry, ok := index.Get(videoName(c))
+		if !ok {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "file not found",
+				"error": "video not found",
+			})
+		}
+
+		assets, err := listVideoAssets(baseDir, entry.Folder)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		files := []MediaFile{newMediaFile(baseDir, *entry)}
+		files[0].Streams = newVideoStreams(entry.Streams)
+		attachProgress(c, progress, files)
+		return c.JSON(VideoDetail{
+			MediaFile: files[0],
+			Assets:    assets,
+		})
+	}
+}
+
+func listVideoAssets(baseDir, folder string) ([]VideoAsset, error) {
+	root := filepath.Join(baseDir, folder)
+	assets := make([]VideoAsset, 0)
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		kind := assetKind(rel)
+		if kind == "" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		assets = append(assets, VideoAsset{
+			Kind:   kind,
+			Path:   rel,
+			URL:    "/videos/" + folder + "/" + rel,
+			SizeKB: (info.Size() + 1023) / 1024,
+		})
+		return nil
+	})
+	return assets, err
+}
+
+// assetKind классифицирует файл из папки видео; сегменты возвращают пустую строку
+func assetKind(rel string) string {
+	dir, _, _ := strings.Cut(rel, "/")
+	switch dir {
+	case "keyframes":
+		return "keyframe"
+	case "nsfw":
+		return "nsfw"
+	case "sprites":
+		return "sprite"
+	}
+
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".ts", ".m4s", ".aac":
+		return ""
+	case ".m3u8":
+		return "playlist"
+	case ".mp4":
+		return "preview"
+	case ".vtt":
+		return "subtitles"
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return "image"
+	}
+	return "file"
+}
+
+// GetVideoMeta - пользовательские метаданные видео из sidecar
+func GetVideoMeta(index *service.VideoIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		meta, err := index.Meta(videoName(c))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(meta)
+	}
+}
+
+// PatchVideoMeta частично меняет метаданные: см. service.VideoMetaPatch
+func PatchVideoMeta(index *service.VideoIndex) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var patch service.VideoMetaPatch
+		if err := c.BodyParser(&patch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid json")
+		}
+
+		meta, err := index.UpdateMeta(videoName(c), patch)
+		if errors.Is(err, service.ErrInvalidMeta) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
 			})
 		}
+		return c.JSON(meta)
+	}
+}
+
+// StreamHLSFile - теперь умеет правильно ставить Content-Type для mp4, jpg, vtt.
+// В отдаваемых плейлистах ссылки на сегменты дополняются подписью, чтобы плеер мог
+// запрашивать их без заголовка Authorization.
+func StreamHLSFile(baseDir string, signer *service.URLSigner) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		signature := middleware.CurrentSignature(c)
+		if signature == nil {
+			// подпись открывает папку видео в том виде, в каком она записана в URL запроса
+			prefix, _ := c.Locals(videoPrefixKey).(string)
+			signature, _ = signer.Sign(middleware.PrincipalName(c), prefix)
+		}
+
+		return sendVideoFile(c, baseDir, videoName(c), videoFile(c), signature.Encode())
+	}
+}
+
+// sendVideoFile отдаёт файл из папки видео; к ссылкам внутри плейлистов дописывается playlistQuery
+func sendVideoFile(c *fiber.Ctx, baseDir, videoname, relativePath, playlistQuery string) error {
+	videoname, ok := entity.CleanVideoPath(videoname)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "invalid path",
+		})
+	}
+	relativePath = filepath.Clean(relativePath) // <-- относительный путь внутри видео папки
+
+	fullDir := filepath.Join(baseDir, filepath.FromSlash(videoname))
+	fullPath := filepath.Join(fullDir, relativePath)
 
-		ext := strings.ToLower(filepath.Ext(fullPath))
-		switch ext {
-		case ".m3u8":
-			c.Response().Header.Set("Content-Type", "application/vnd.apple.mpegurl")
-		case ".ts":
-			c.Response().Header.Set("Content-Type", "video/MP2T")
-		case ".jpg", ".jpeg":
-			c.Response().Header.Set("Content-Type", "image/jpeg")
-		case ".mp4":
-			c.Response().Header.Set("Content-Type", "video/mp4")
-		case ".vtt":
-			c.Response().Header.Set("Content-Type", "text/vtt")
-		case ".zip":
-			c.Response().Header.Set("Content-Type", "application/zip")
-		default:
-			c.Response().Header.Set("Content-Type", "application/octet-stream")
+	if !strings.HasPrefix(fullPath, fullDir+string(filepath.Separator)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "invalid path",
+		})
+	}
+
+	if _, err := os.Stat(fullPath); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "file not found",
+		})
+	}
+
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	switch ext {
+	case ".m3u8":
+		c.Response().Header.Set("Content-Type", "application/vnd.apple.mpegurl")
+		if playlistQuery == "" {
+			break
 		}
 
-		return c.SendFile(fullPath)
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to read playlist",
+			})
+		}
+		return c.Send(signPlaylist(data, playlistQuery))
+	case ".ts":
+		c.Response().Header.Set("Content-Type", "video/MP2T")
+	case ".jpg", ".jpeg":
+		c.Response().Header.Set("Content-Type", "image/jpeg")
+	case ".mp4":
+		c.Response().Header.Set("Content-Type", "video/mp4")
+	case ".vtt":
+		c.Response().Header.Set("Content-Type", "text/vtt")
+	case ".zip":
+		c.Response().Header.Set("Content-Type", "application/zip")
+	default:
+		c.Response().Header.Set("Content-Type", "application/octet-stream")
 	}
+
+	return c.SendFile(fullPath)
 }
 
-func DeleteVideo(baseDir string) fiber.Handler {
+func DeleteVideo(baseDir string, index *service.VideoIndex, audit *service.AuditService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		videoname := filepath.Base(c.Params("videoname"))
-		fullPath := filepath.Join(baseDir, videoname)
+		videoname := videoName(c)
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(videoname))
 
 		if err := os.RemoveAll(fullPath); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "failed to delete",
 			})
 		}
+		index.Remove(videoname)
 
+		recordAudit(c, audit, service.AuditDelete, videoname, "")
 		return c.JSON(fiber.Map{
 			"message": "deleted",
 		})
 	}
 }
+
+// SimilarVideo - видео с похожими ключевыми кадрами; similarity - доля совпавших кадров обоих видео
+type SimilarVideo struct {
+	MediaFile
+	Similarity    float64 `json:"similarity"`
+	MatchedFrames int     `json:"matchedFrames"`
+}
+
+// GetSimilarVideos - GET /videos/:videoname/similar?distance=10&limit=20: перекодированные копии
+// и почти одинаковые видео по dHash ключевых кадров, самые похожие первыми
+func GetSimilarVideos(baseDir string, index *service.VideoIndex, progress *service.ProgressService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		distance := service.DefaultFrameDistance
+		if c.Query("distance") != "" {
+			d, err := queryNonNegative(c, "distance")
+			if err != nil {
+				return err
+			}
+			distance = d
+		}
+		if distance > 32 {
+			return fiber.NewError(fiber.StatusBadRequest, "distance must be 0-32")
+		}
+		limit, err := queryLimit(c, defaultPageLimit, maxPageLimit)
+		if err != nil {
+			return err
+		}
+
+		similar, err := index.Similar(videoName(c), distance)
+		switch {
+		case errors.Is(err, service.ErrVideoNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, service.ErrNoFrameHashes):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if len(similar) > limit {
+			similar = similar[:limit]
+		}
+
+		files := make([]MediaFile, 0, len(similar))
+		for _, s := range similar {
+			files = append(files, newMediaFile(baseDir, s.Entry))
+		}
+		attachProgress(c, progress, files)
+
+		items := make([]SimilarVideo, 0, len(similar))
+		for i, s := range similar {
+			items = append(items, SimilarVideo{
+				MediaFile:     files[i],
+				Similarity:    s.Score,
+				MatchedFrames: s.MatchedFrames,
+			})
+		}
+		return c.JSON(items)
+	}
+}
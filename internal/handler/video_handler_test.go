@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"mediafs/internal/service"
+)
+
+func TestResolveVideoRejectsDotSegments(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, folder := range []string{"a", "secret"} {
+		dir := filepath.Join(baseDir, folder)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.0,\nseg0.ts\n#EXT-X-ENDLIST\n"
+		if err := os.WriteFile(filepath.Join(dir, "playlist.m3u8"), []byte(playlist), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := service.NewVideoIndex(baseDir, filepath.Join(t.TempDir(), "index.json"))
+	entry, ok := index.Get("a")
+	if !ok {
+		t.Fatal("video a not indexed")
+	}
+
+	app := fiber.New()
+	app.Get("/videos/:videoname/*", ResolveVideo(index), func(c *fiber.Ctx) error {
+		return c.SendString(videoName(c) + "|" + videoFile(c))
+	})
+
+	cases := map[string]int{
+		"/videos/" + entry.ID + "/seg0.ts":               fiber.StatusOK,
+		"/videos/a/seg0.ts":                              fiber.StatusOK,
+		"/videos/" + entry.ID + "/..%2Fsecret/seg0.ts":   fiber.StatusBadRequest,
+		"/videos/" + entry.ID + "/%2E%2E/secret/seg0.ts": fiber.StatusBadRequest,
+		"/videos/a%2F..%2Fsecret/seg0.ts":                fiber.StatusBadRequest,
+	}
+	for path, want := range cases {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
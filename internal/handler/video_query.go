@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"encoding/base64"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// VideoPage - страница списка видео; Total считается после фильтров.
+// ChangeToken - отправная точка для GET /videos/changes после полной загрузки списка.
+type VideoPage struct {
+	Items       []MediaFile `json:"items"`
+	Total       int         `json:"total"`
+	NextCursor  string      `json:"nextCursor,omitempty"`
+	ChangeToken string      `json:"changeToken,omitempty"`
+}
+
+// videoQuery - параметры GET /videos: фильтры, сортировка и страница
+type videoQuery struct {
+	sort         string
+	desc         bool
+	limit        int
+	offset       int
+	minDuration  int
+	maxDuration  int
+	resolutions  []string
+	tags         []string
+	hasKeyframes *bool
+	hasNsfw      *bool
+	streams      bool
+}
+
+var videoSortKeys = map[string]func(a, b *MediaFile) int{
+	"name":      func(a, b *MediaFile) int { return strings.Compare(a.Name, b.Name) },
+	"createdAt": func(a, b *MediaFile) int { return strings.Compare(a.CreatedAt, b.CreatedAt) },
+	"duration":  func(a, b *MediaFile) int { return a.Duration - b.Duration },
+	"sizeMB":    func(a, b *MediaFile) int { return a.SizeMB - b.SizeMB },
+	"resolution": func(a, b *MediaFile) int {
+		return resolutionPixels(a.Resolution) - resolutionPixels(b.Resolution)
+	},
+}
+
+// parseVideoQuery разбирает ?limit=&cursor=&sort=&order=asc|desc&minDuration=&maxDuration=
+// &resolution=1920x1080,720p&hasKeyframes=&hasNsfw=&tag=a,b (видео должно иметь все теги)
+// и &streams=true (добавить в ответ кодеки и дорожки)
+func parseVideoQuery(c *fiber.Ctx) (*videoQuery, error) {
+	q := &videoQuery{
+		sort: c.Query("sort", "name"),
+	}
+	if _, ok := videoSortKeys[q.sort]; !ok {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid sort, want name, createdAt, duration, sizeMB or resolution")
+	}
+	switch c.Query("order", "asc") {
+	case "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid order, want asc or desc")
+	}
+	limit, err := queryLimit(c, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		return nil, err
+	}
+	q.limit = limit
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid cursor")
+		}
+		q.offset = offset
+	}
+
+	if q.minDuration, err = queryNonNegative(c, "minDuration"); err != nil {
+		return nil, err
+	}
+	if q.maxDuration, err = queryNonNegative(c, "maxDuration"); err != nil {
+		return nil, err
+	}
+	if q.hasKeyframes, err = queryOptionalBool(c, "hasKeyframes"); err != nil {
+		return nil, err
+	}
+	if q.hasNsfw, err = queryOptionalBool(c, "hasNsfw"); err != nil {
+		return nil, err
+	}
+	streams, err := queryOptionalBool(c, "streams")
+	if err != nil {
+		return nil, err
+	}
+	q.streams = streams != nil && *streams
+	for _, r := range strings.Split(c.Query("resolution"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			q.resolutions = append(q.resolutions, strings.ToLower(r))
+		}
+	}
+	for _, tag := range strings.Split(c.Query("tag"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			q.tags = append(q.tags, tag)
+		}
+	}
+	return q, nil
+}
+
+func (q *videoQuery) match(f *MediaFile) bool {
+	if q.minDuration > 0 && f.Duration < q.minDuration {
+		return false
+	}
+	if q.maxDuration > 0 && f.Duration > q.maxDuration {
+		return false
+	}
+	if q.hasKeyframes != nil && *q.hasKeyframes != (f.KeyframesURL != nil) {
+		return false
+	}
+	if q.hasNsfw != nil && *q.hasNsfw != (f.NsfwframesURL != nil) {
+		return false
+	}
+	if !hasAllTags(f.Tags, q.tags) {
+		return false
+	}
+	if len(q.resolutions) == 0 {
+		return true
+	}
+	for _, r := range q.resolutions {
+		if matchResolution(f.Resolution, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply фильтрует, сортирует и режет список на страницу
+func (q *videoQuery) apply(files []MediaFile) VideoPage {
+	filtered := make([]MediaFile, 0, len(files))
+	for i := range files {
+		if q.match(&files[i]) {
+			filtered = append(filtered, files[i])
+		}
+	}
+
+	compare := videoSortKeys[q.sort]
+	sort.SliceStable(filtered, func(i, j int) bool {
+		cmp := compare(&filtered[i], &filtered[j])
+		if cmp == 0 {
+			// одинаковые значения упорядочиваем по имени, чтобы страницы не перемешивались
+			cmp = strings.Compare(filtered[i].Name, filtered[j].Name)
+		}
+		if q.desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	page := VideoPage{Items: []MediaFile{}, Total: len(filtered)}
+	if q.offset >= len(filtered) {
+		return page
+	}
+	end := min(q.offset+q.limit, len(filtered))
+	page.Items = filtered[q.offset:end]
+	if end < len(filtered) {
+		page.NextCursor = encodeCursor(end)
+	}
+	return page
+}
+
+// hasAllTags сравнивает теги без учёта регистра
+func hasAllTags(tags, want []string) bool {
+	for _, w := range want {
+		if !slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, w) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchResolution сравнивает "1920x1080" целиком или по высоте: "1080p"
+func matchResolution(resolution, want string) bool {
+	resolution = strings.ToLower(resolution)
+	if resolution == want {
+		return true
+	}
+	height, ok := strings.CutSuffix(want, "p")
+	if !ok {
+		return false
+	}
+	_, h, found := strings.Cut(resolution, "x")
+	return found && h == height
+}
+
+func resolutionPixels(resolution string) int {
+	w, h, ok := strings.Cut(strings.ToLower(resolution), "x")
+	if !ok {
+		return 0
+	}
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return width * height
+}
+
+// Курсор - непрозрачная для клиента позиция в отсортированном списке
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fiber.ErrBadRequest
+	}
+	return offset, nil
+}
+
+func queryNonNegative(c *fiber.Ctx, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid "+key)
+	}
+	return value, nil
+}
+
+// queryLimit разбирает ?limit= в пределах 1..max; без параметра возвращает def
+func queryLimit(c *fiber.Ctx, def, max int) (int, error) {
+	if c.Query("limit") == "" {
+		return def, nil
+	}
+	limit, err := queryNonNegative(c, "limit")
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 || limit > max {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(max))
+	}
+	return limit, nil
+}
+
+func queryOptionalBool(c *fiber.Ctx, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid "+key+", want true or false")
+	}
+	return &value, nil
+}
@@ -1,18 +1,177 @@
 package middleware
 
 import (
+	"net/url"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"mediafs/internal/service"
 )
 
-func BearerAuthMiddleware(auth *service.AuthService) fiber.Handler {
+const (
+	userKey      = "user"
+	sessionKey   = "session"
+	apiKeyKey    = "apikey"
+	signatureKey = "signature"
+	signedOKKey  = "signedok"
+)
+
+// apiKeyPrincipal - префикс имени субъекта для API-ключей в подписанных ссылках
+const apiKeyPrincipal = "key:"
+
+// BearerAuthMiddleware пускает запросы с токеном сессии или API-ключом, а GET/HEAD - ещё и по подписанной ссылке
+func BearerAuthMiddleware(auth *service.AuthService, keys *service.APIKeyService, signer *service.URLSigner) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") || !auth.CheckToken(strings.TrimPrefix(authHeader, "Bearer ")) {
+		if authHeader == "" && c.Query(service.SignParamSig) != "" {
+			return signedURLAuth(c, auth, keys, signer)
+		}
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if strings.HasPrefix(token, service.APIKeyPrefix) {
+			key, ok := keys.Check(token)
+			if !ok {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+			}
+			c.Locals(apiKeyKey, key)
+			return c.Next()
+		}
+
+		user, session, ok := auth.CheckToken(token)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+
+		c.Locals(userKey, user)
+		c.Locals(sessionKey, session)
+		return c.Next()
+	}
+}
+
+func signedURLAuth(c *fiber.Ctx, auth *service.AuthService, keys *service.APIKeyService, signer *service.URLSigner) error {
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	principal, ok := signer.Verify(c.Path(),
+		c.Query(service.SignParamUser),
+		c.Query(service.SignParamScope),
+		c.Query(service.SignParamExpires),
+		c.Query(service.SignParamSig))
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired signature"})
+	}
+
+	// Подпись действует, только пока жив выдавший её пользователь или ключ
+	if id, isKey := strings.CutPrefix(principal, apiKeyPrincipal); isKey {
+		key, ok := keys.Get(id)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		c.Locals(apiKeyKey, key)
+	} else {
+		user, ok := auth.User(principal)
+		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 		}
+		c.Locals(userKey, &user)
+	}
+
+	signature := url.Values{}
+	for _, key := range []string{service.SignParamUser, service.SignParamScope, service.SignParamExpires, service.SignParamSig} {
+		signature.Set(key, c.Query(key))
+	}
+	c.Locals(signatureKey, signature)
+	return c.Next()
+}
+
+// AllowSigned открывает маршрут для запросов по подписанной ссылке. Ставится только на
+// маршруты, отдающие файлы плееру; остальные проверки прав такие запросы отклоняют,
+// даже если путь попадает в scope подписи (например, /videos/<name>/meta).
+func AllowSigned() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(signedOKKey, true)
+		return c.Next()
+	}
+}
+
+// signedDenied - запрос пришёл по подписанной ссылке на маршрут без AllowSigned
+func signedDenied(c *fiber.Ctx) bool {
+	allowed, _ := c.Locals(signedOKKey).(bool)
+	return CurrentSignature(c) != nil && !allowed
+}
+
+func signedDeniedError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "signed URLs are only valid for media files"})
+}
+
+// RequireScope проверяет право по роли пользователя или по списку scopes API-ключа
+func RequireScope(scope service.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if signedDenied(c) {
+			return signedDeniedError(c)
+		}
+		allowed := false
+		if key := CurrentAPIKey(c); key != nil {
+			allowed = key.HasScope(scope)
+		} else if user := CurrentUser(c); user != nil {
+			allowed = user.Role.HasScope(scope)
+		}
+
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden", "scope": scope})
+		}
+		return c.Next()
+	}
+}
+
+// RequireUser закрывает маршруты, которые имеют смысл только для вошедшего пользователя
+func RequireUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if signedDenied(c) {
+			return signedDeniedError(c)
+		}
+		if CurrentUser(c) == nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "user session required"})
+		}
 		return c.Next()
 	}
 }
+
+// CurrentUser возвращает пользователя, установленного BearerAuthMiddleware
+func CurrentUser(c *fiber.Ctx) *service.User {
+	user, _ := c.Locals(userKey).(*service.User)
+	return user
+}
+
+// CurrentSession возвращает сессию, с токеном которой пришёл запрос
+func CurrentSession(c *fiber.Ctx) *service.Session {
+	session, _ := c.Locals(sessionKey).(*service.Session)
+	return session
+}
+
+// CurrentAPIKey возвращает API-ключ, с которым пришёл запрос
+func CurrentAPIKey(c *fiber.Ctx) *service.APIKey {
+	key, _ := c.Locals(apiKeyKey).(*service.APIKey)
+	return key
+}
+
+// PrincipalName - имя пользователя или "key:<id>" для API-ключа
+func PrincipalName(c *fiber.Ctx) string {
+	if key := CurrentAPIKey(c); key != nil {
+		return apiKeyPrincipal + key.ID
+	}
+	if user := CurrentUser(c); user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+// CurrentSignature возвращает параметры подписи, если запрос пришёл по подписанной ссылке
+func CurrentSignature(c *fiber.Ctx) url.Values {
+	signature, _ := c.Locals(signatureKey).(url.Values)
+	return signature
+}
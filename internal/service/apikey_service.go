@@ -0,0 +1,256 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyPrefix отличает API-ключи от токенов сессий в заголовке Authorization
+const APIKeyPrefix = "mfs_"
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey - долгоживущий ключ для скриптов; хранится только хэш секрета
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"`
+	Scopes     []Scope   `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// HasScope сообщает, выдано ли ключу указанное право
+func (k *APIKey) HasScope(scope Scope) bool {
+	return scopesContain(k.Scopes, scope)
+}
+
+// APIKeyService хранит ключи в apikeys.json. Сервер и `mediafs apikey` пишут один файл,
+// поэтому изменения идут через update под flock на apikeys.json.lock, как в FileAuthStore.
+type APIKeyService struct {
+	path    string
+	mu      sync.Mutex
+	keys    map[string]*APIKey
+	modTime time.Time
+	size    int64
+}
+
+func NewAPIKeyService(path string) *APIKeyService {
+	return &APIKeyService{
+		path: path,
+		keys: map[string]*APIKey{},
+	}
+}
+
+// Load читает файл ключей; отсутствие файла означает, что ключей ещё нет
+func (s *APIKeyService) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Create создаёт ключ и возвращает его открытое значение - больше его узнать нельзя
+func (s *APIKeyService) Create(name string, scopes []Scope) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", nil, err
+	}
+	plain := APIKeyPrefix + id + "_" + secret
+
+	key := &APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashToken(plain),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.update(func(keys map[string]*APIKey) error {
+		keys[id] = key
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	copied := *key
+	return plain, &copied, nil
+}
+
+// List возвращает ключи в порядке создания
+func (s *APIKeyService) List() []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfChanged()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+func (s *APIKeyService) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func(keys map[string]*APIKey) error {
+		if _, ok := keys[id]; !ok {
+			return ErrAPIKeyNotFound
+		}
+		delete(keys, id)
+		return nil
+	})
+}
+
+// Check находит ключ по его открытому значению. Файл перечитывается при изменении,
+// поэтому ключи, созданные или отозванные из CLI, действуют без перезапуска сервера.
+func (s *APIKeyService) Check(plain string) (*APIKey, bool) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(plain, APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(plain, APIKeyPrefix) {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfChanged()
+
+	key, ok := s.keys[id]
+	if !ok || !hashEqual(key.Hash, hashToken(plain)) {
+		return nil, false
+	}
+	if now := time.Now(); now.Sub(key.LastUsedAt) > sessionTouchInterval {
+		err := s.update(func(keys map[string]*APIKey) error {
+			if k, ok := keys[id]; ok {
+				k.LastUsedAt = now
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("❌ Failed to update API key last use: %v", err)
+		}
+		// ключ могли отозвать из CLI, пока мы ждали блокировку
+		if key, ok = s.keys[id]; !ok {
+			return nil, false
+		}
+	}
+	copied := *key
+	return &copied, true
+}
+
+// Get возвращает ключ по ID
+func (s *APIKeyService) Get(id string) (*APIKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfChanged()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *key
+	return &copied, true
+}
+
+func (s *APIKeyService) load() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.keys = map[string]*APIKey{}
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys := map[string]*APIKey{}
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return err
+	}
+	s.keys = keys
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+func (s *APIKeyService) reloadIfChanged() {
+	info, err := os.Stat(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	changed := err == nil && (!info.ModTime().Equal(s.modTime) || info.Size() != s.size)
+	if changed || (err != nil && !s.modTime.IsZero()) {
+		if err := s.load(); err != nil {
+			log.Printf("❌ Failed to reload API keys: %v", err)
+		}
+	}
+}
+
+// update применяет fn к копии ключей, перечитанных под блокировкой файла, и сохраняет её;
+// при ошибке состояние не меняется. Вызывающий должен держать s.mu.
+func (s *APIKeyService) update(fn func(keys map[string]*APIKey) error) error {
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	next := make(map[string]*APIKey, len(s.keys))
+	for id, k := range s.keys {
+		copied := *k
+		next[id] = &copied
+	}
+	if err := fn(next); err != nil {
+		return err
+	}
+	if err := s.save(next); err != nil {
+		return err
+	}
+	s.keys = next
+	return nil
+}
+
+func (s *APIKeyService) save(keys map[string]*APIKey) error {
+	bytes, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, bytes, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
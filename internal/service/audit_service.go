@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxAuditField ограничивает текстовые поля записи: имя пользователя в неудачном входе
+	// приходит от неаутентифицированного клиента и может быть любой длины
+	maxAuditField = 256
+	// maxAuditLine - строки длиннее (например, записанные до ограничения полей) Query пропускает
+	maxAuditLine = 64 * 1024
+)
+
+type AuditAction string
+
+const (
+	AuditLogin       AuditAction = "login"
+	AuditLoginFailed AuditAction = "login_failed"
+	AuditLockout     AuditAction = "lockout"
+	AuditLogout      AuditAction = "logout"
+	AuditDelete      AuditAction = "delete"
+	AuditCut         AuditAction = "cut"
+	AuditShareCreate AuditAction = "share_create"
+	AuditShareDelete AuditAction = "share_delete"
+)
+
+type AuditEvent struct {
+	Time    time.Time   `json:"time"`
+	Action  AuditAction `json:"action"`
+	Actor   string      `json:"actor,omitempty"`
+	IP      string      `json:"ip,omitempty"`
+	Session string      `json:"session,omitempty"`
+	Target  string      `json:"target,omitempty"`
+	Detail  string      `json:"detail,omitempty"`
+}
+
+// AuditFilter - условия выборки; нулевые поля не ограничивают результат
+type AuditFilter struct {
+	From    time.Time
+	To      time.Time
+	Actions []AuditAction
+	Limit   int
+}
+
+func (f AuditFilter) match(e AuditEvent) bool {
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+	if len(f.Actions) == 0 {
+		return true
+	}
+	for _, a := range f.Actions {
+		if a == e.Action {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditService ведёт журнал в формате JSON lines, в который только дописываются записи
+type AuditService struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewAuditService(path string) *AuditService {
+	return &AuditService{path: path}
+}
+
+func (s *AuditService) Record(e AuditEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.Actor = truncateAuditField(e.Actor)
+	e.Target = truncateAuditField(e.Target)
+	e.Detail = truncateAuditField(e.Detail)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query возвращает подходящие записи, самые новые первыми
+func (s *AuditService) Query(filter AuditFilter) ([]AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]AuditEvent, 0)
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var buf []byte
+	for {
+		line, ok, err := readAuditLine(reader, buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = line
+		if !ok {
+			continue
+		}
+
+		var e AuditEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		if filter.match(e) {
+			events = append(events, e)
+		}
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+// readAuditLine читает одну строку журнала в buf. Строка длиннее maxAuditLine дочитывается
+// и отбрасывается (ok = false), чтобы одна испорченная запись не ломала весь журнал.
+func readAuditLine(r *bufio.Reader, buf []byte) (line []byte, ok bool, err error) {
+	buf, ok = buf[:0], true
+	for {
+		part, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok && len(buf)+len(part) <= maxAuditLine {
+			buf = append(buf, part...)
+		} else {
+			ok = false
+		}
+		if !isPrefix {
+			return buf, ok, nil
+		}
+	}
+}
+
+// truncateAuditField обрезает поле до maxAuditField байт, не разрезая символ UTF-8
+func truncateAuditField(s string) string {
+	if len(s) <= maxAuditField {
+		return s
+	}
+	cut := maxAuditField
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
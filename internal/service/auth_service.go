@@ -1,71 +1,364 @@
 package service
 
 import (
-	"encoding/json"
-	"os"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type AuthData struct {
+// DefaultUsername - пользователь, в которого переносится пароль из старого однопользовательского auth.json
+const DefaultUsername = "admin"
+
+const (
+	DefaultTokenTTL   = 24 * time.Hour
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+	ErrLastAdmin    = errors.New("cannot remove or demote the last admin")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q (want viewer, editor or admin)", s)
+}
+
+func (r Role) level() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Allows сообщает, покрывает ли роль требуемую: admin > editor > viewer
+func (r Role) Allows(required Role) bool {
+	return r.level() > 0 && r.level() >= required.level()
+}
+
+type User struct {
+	Username     string    `json:"username"`
 	PasswordHash string    `json:"password_hash"`
-	Token        string    `json:"token"`
+	Role         Role      `json:"role"`
 	LastAuthTime time.Time `json:"last_auth_time"`
+
+	// Второй фактор: секрет TOTP, последний принятый шаг и хэши кодов восстановления
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
+type AuthData struct {
+	Version  int                 `json:"version"`
+	Users    map[string]*User    `json:"users"`
+	Sessions map[string]*Session `json:"sessions"`
+	// Ключ HMAC для подписанных ссылок, создаётся при первом запуске сервера
+	URLSecret string `json:"url_secret,omitempty"`
+
+	// Поля старого однопользовательского формата, читаются только миграцией
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Token        string     `json:"token,omitempty"`
+	LastAuthTime *time.Time `json:"last_auth_time,omitempty"`
+}
+
+func newAuthData() *AuthData {
+	return &AuthData{
+		Users:    map[string]*User{},
+		Sessions: map[string]*Session{},
+	}
+}
+
+// clone делает глубокую копию, чтобы изменения применялись к памяти только после успешной записи
+func (d *AuthData) clone() *AuthData {
+	c := *d
+	c.Users = make(map[string]*User, len(d.Users))
+	for name, u := range d.Users {
+		copied := *u
+		copied.RecoveryCodes = slices.Clone(u.RecoveryCodes)
+		c.Users[name] = &copied
+	}
+	c.Sessions = make(map[string]*Session, len(d.Sessions))
+	for id, s := range d.Sessions {
+		copied := *s
+		c.Sessions[id] = &copied
+	}
+	return &c
+}
+
+// AuthService хранит пользователей и сессии. Все обращения к данным идут под мьютексом,
+// а изменения сначала записываются в store и только потом становятся видны.
+// Перед каждым обращением данные перечитываются, если их поменял другой процесс (CLI).
 type AuthService struct {
-	path string
-	data *AuthData
+	store      AuthStore
+	tokenTTL   time.Duration
+	refreshTTL time.Duration
+	mu         sync.Mutex
+	data       *AuthData
 }
 
-func NewAuthService(path string) *AuthService {
+func NewAuthService(store AuthStore) *AuthService {
 	return &AuthService{
-		path: path,
-		data: &AuthData{},
+		store:      store,
+		tokenTTL:   DefaultTokenTTL,
+		refreshTTL: DefaultRefreshTTL,
+		data:       newAuthData(),
 	}
 }
 
+// SetTTL задаёт время жизни access- и refresh-токенов для новых выдач
+func (a *AuthService) SetTTL(token, refresh time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokenTTL = token
+	a.refreshTTL = refresh
+}
+
 func (a *AuthService) Load() error {
-	content, err := os.ReadFile(a.path)
+	data, err := a.store.Load()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.data = data
+	a.mu.Unlock()
+	return nil
+}
+
+// lock берёт a.mu и подхватывает изменения, сделанные другим процессом
+func (a *AuthService) lock() {
+	a.mu.Lock()
+	if err := a.reloadIfChanged(); err != nil {
+		log.Printf("❌ Failed to reload auth data: %v", err)
+	}
+}
+
+// reloadIfChanged перечитывает store, если его изменили в обход этого экземпляра.
+// Вызывающий должен держать a.mu.
+func (a *AuthService) reloadIfChanged() error {
+	if !a.store.Changed() {
+		return nil
+	}
+	data, err := a.store.Load()
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(content, a.data)
+	a.data = data
+	return nil
 }
 
-func (a *AuthService) Save() error {
-	bytes, err := json.MarshalIndent(a.data, "", "  ")
+// update применяет fn к копии данных и сохраняет её; при ошибке состояние не меняется.
+// Чтение-изменение-запись идёт под блокировкой store, поэтому fn видит и изменения
+// других процессов и ничего из них не затирает. Вызывающий должен держать a.mu.
+func (a *AuthService) update(fn func(d *AuthData) error) error {
+	unlock, err := a.store.Lock()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(a.path, bytes, 0600)
+	defer unlock()
+	if err := a.reloadIfChanged(); err != nil {
+		return err
+	}
+
+	next := a.data.clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	if err := a.store.Save(next); err != nil {
+		return err
+	}
+	a.data = next
+	return nil
+}
+
+// HasUsers сообщает, заведён ли хотя бы один пользователь
+func (a *AuthService) HasUsers() bool {
+	a.lock()
+	defer a.mu.Unlock()
+	return len(a.data.Users) > 0
+}
+
+// Users возвращает копии всех пользователей, отсортированные по имени
+func (a *AuthService) Users() []User {
+	a.lock()
+	defer a.mu.Unlock()
+
+	users := make([]User, 0, len(a.data.Users))
+	for _, u := range a.data.Users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
 }
 
-func (a *AuthService) SetPassword(password string) error {
+// User возвращает копию пользователя по имени
+func (a *AuthService) User(username string) (User, bool) {
+	a.lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.data.Users[username]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+func (a *AuthService) AddUser(username, password string, role Role) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	a.data.PasswordHash = string(hash)
-	a.data.Token = ""
-	a.data.LastAuthTime = time.Time{}
-	return a.Save()
+
+	a.lock()
+	defer a.mu.Unlock()
+	return a.update(func(d *AuthData) error {
+		if _, ok := d.Users[username]; ok {
+			return ErrUserExists
+		}
+		d.Users[username] = &User{
+			Username:     username,
+			PasswordHash: string(hash),
+			Role:         role,
+		}
+		return nil
+	})
+}
+
+func (a *AuthService) RemoveUser(username string) error {
+	a.lock()
+	defer a.mu.Unlock()
+	return a.update(func(d *AuthData) error {
+		u, ok := d.Users[username]
+		if !ok {
+			return ErrUserNotFound
+		}
+		if u.Role == RoleAdmin && d.adminCount() == 1 {
+			return ErrLastAdmin
+		}
+		delete(d.Users, username)
+		d.revokeUserSessions(username)
+		return nil
+	})
 }
 
-func (a *AuthService) CheckPassword(password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(a.data.PasswordHash), []byte(password)) == nil
+func (a *AuthService) SetRole(username string, role Role) error {
+	a.lock()
+	defer a.mu.Unlock()
+	return a.update(func(d *AuthData) error {
+		u, ok := d.Users[username]
+		if !ok {
+			return ErrUserNotFound
+		}
+		if u.Role == RoleAdmin && role != RoleAdmin && d.adminCount() == 1 {
+			return ErrLastAdmin
+		}
+		u.Role = role
+		return nil
+	})
 }
 
-func (a *AuthService) GenerateToken() string {
-	token := uuid.NewString()
-	a.data.Token = token
-	a.data.LastAuthTime = time.Now()
-	a.Save()
-	return token
+// SetPassword меняет пароль пользователя и завершает все его сессии
+func (a *AuthService) SetPassword(username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+	return a.update(func(d *AuthData) error {
+		u, ok := d.Users[username]
+		if !ok {
+			return ErrUserNotFound
+		}
+		u.PasswordHash = string(hash)
+		u.LastAuthTime = time.Time{}
+		d.revokeUserSessions(username)
+		return nil
+	})
 }
 
-func (a *AuthService) CheckToken(token string) bool {
-	return token == a.data.Token
+func (a *AuthService) CheckPassword(username, password string) bool {
+	a.lock()
+	u, ok := a.data.Users[username]
+	var hash string
+	if ok {
+		hash = u.PasswordHash
+	}
+	a.mu.Unlock()
+
+	if !ok {
+		// сравнение с пустышкой занимает столько же, сколько с настоящим хэшем:
+		// по времени ответа нельзя узнать, есть ли такой пользователь
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// dummyPasswordHash - bcrypt-хэш стоимости bcrypt.DefaultCost (как у паролей пользователей)
+// для входа под несуществующим именем; подобрать к нему пароль ничего не даёт
+const dummyPasswordHash = "$2a$10$VLtKmetjBh4vUkaPvnIewOUSd8RZ0l/LWZvbRGV.w1jMyaTIuecmi"
+
+// URLSecret возвращает ключ для подписи ссылок, генерируя и сохраняя его при первом обращении
+func (a *AuthService) URLSecret() ([]byte, error) {
+	a.lock()
+	defer a.mu.Unlock()
+
+	if a.data.URLSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		err := a.update(func(d *AuthData) error {
+			// ключ мог успеть создать другой процесс
+			if d.URLSecret == "" {
+				d.URLSecret = base64.StdEncoding.EncodeToString(secret)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return base64.StdEncoding.DecodeString(a.data.URLSecret)
+}
+
+func (d *AuthData) adminCount() int {
+	n := 0
+	for _, u := range d.Users {
+		if u.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// authSchemaVersion - текущая версия формата auth.json
+const authSchemaVersion = 1
+
+// AuthStore хранит данные аутентификации целиком. AuthService держит их в памяти
+// и вызывает Save с новой копией после каждого изменения.
+type AuthStore interface {
+	Load() (*AuthData, error)
+	Save(data *AuthData) error
+	// Changed сообщает, что данные изменил другой процесс (например, `mediafs user`)
+	// после последних Load или Save этого экземпляра
+	Changed() bool
+	// Lock не даёт другим процессам менять данные, пока идёт чтение-изменение-запись
+	Lock() (unlock func(), err error)
+}
+
+// authMigrations[i] переводит данные из версии i в версию i+1
+var authMigrations = []func(*AuthData){
+	migrateSingleUser,
+}
+
+// FileAuthStore - auth.json на диске с атомарной записью и миграцией схемы при загрузке.
+// Сервер и CLI пишут один файл, поэтому запись идёт под flock на auth.json.lock
+// (сам auth.json заменяется через rename, блокировка на нём не держится).
+type FileAuthStore struct {
+	path    string
+	modTime time.Time
+	size    int64
+	// locked - flock уже взят этим экземпляром (Load внутри AuthService.update);
+	// повторный flock из того же процесса ждал бы сам себя
+	locked bool
+}
+
+func NewFileAuthStore(path string) *FileAuthStore {
+	return &FileAuthStore{path: path}
+}
+
+func (s *FileAuthStore) Path() string {
+	return s.path
+}
+
+func (s *FileAuthStore) Load() (*AuthData, error) {
+	data, err := s.read()
+	if err != nil || data.Version == authSchemaVersion {
+		return data, err
+	}
+
+	// миграция пишет файл, поэтому идёт под той же блокировкой, что и AuthService.update;
+	// под ней файл перечитывается - его мог уже перевести другой процесс
+	if !s.locked {
+		unlock, err := s.Lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	if data, err = s.read(); err != nil || data.Version == authSchemaVersion {
+		return data, err
+	}
+	for v := data.Version; v < authSchemaVersion; v++ {
+		authMigrations[v](data)
+	}
+	if err := s.Save(data); err != nil {
+		return nil, fmt.Errorf("save migrated %s: %w", s.path, err)
+	}
+	return data, nil
+}
+
+// read читает и разбирает файл без миграции; modTime и size запоминаются только для
+// файла текущей версии, старый всё равно будет перезаписан
+func (s *FileAuthStore) read() (*AuthData, error) {
+	// stat до чтения: если файл заменят между ними, следующий Changed это заметит
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := newAuthData()
+	if err := json.Unmarshal(content, data); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if data.Version > authSchemaVersion {
+		return nil, fmt.Errorf("%s has schema version %d, this build supports up to %d", s.path, data.Version, authSchemaVersion)
+	}
+	if data.Users == nil {
+		data.Users = map[string]*User{}
+	}
+	if data.Sessions == nil {
+		data.Sessions = map[string]*Session{}
+	}
+	if data.Version == authSchemaVersion {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return data, nil
+}
+
+func (s *FileAuthStore) Save(data *AuthData) error {
+	data.Version = authSchemaVersion
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, bytes, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+func (s *FileAuthStore) Changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}
+
+func (s *FileAuthStore) Lock() (func(), error) {
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	s.locked = true
+	return func() {
+		s.locked = false
+		unlock()
+	}, nil
+}
+
+// migrateSingleUser (0 -> 1) переносит единственный пароль старого формата в пользователя admin
+func migrateSingleUser(data *AuthData) {
+	if data.PasswordHash != "" && len(data.Users) == 0 {
+		admin := &User{
+			Username:     DefaultUsername,
+			PasswordHash: data.PasswordHash,
+			Role:         RoleAdmin,
+		}
+		if data.LastAuthTime != nil {
+			admin.LastAuthTime = *data.LastAuthTime
+		}
+		data.Users[DefaultUsername] = admin
+	}
+	data.PasswordHash = ""
+	data.Token = ""
+	data.LastAuthTime = nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxJournalEntries = 5000
+
+var (
+	ErrInvalidChangeToken = errors.New("invalid change token")
+	// ErrChangeTokenExpired - журнал уже сжат дальше токена, клиенту нужна полная синхронизация
+	ErrChangeTokenExpired = errors.New("change token too old, resync")
+)
+
+// JournalEntry - одно изменение индекса под порядковым номером журнала
+type JournalEntry struct {
+	Seq    uint64          `json:"seq"`
+	Kind   VideoChangeKind `json:"kind"`
+	ID     string          `json:"id"`
+	Folder string          `json:"folder"`
+	Time   time.Time       `json:"time"`
+}
+
+// ChangeSet - изменения видео после токена, свёрнутые до итогового состояния каждого ID
+type ChangeSet struct {
+	Added   []string
+	Updated []string
+	Deleted []string
+	Token   string
+	More    bool
+}
+
+type journalFile struct {
+	// Epoch меняется при создании журнала заново, чтобы старые токены не совпали с новыми номерами
+	Epoch   string         `json:"epoch"`
+	Floor   uint64         `json:"floor"`
+	Seq     uint64         `json:"seq"`
+	Entries []JournalEntry `json:"entries"`
+}
+
+// ChangeJournal хранит в .meta последние изменения индекса, чтобы клиенты с локальной копией
+// библиотеки догружали только разницу. Старые записи отбрасываются; токен старше
+// отброшенных записей требует полной синхронизации.
+type ChangeJournal struct {
+	path string
+	mu   sync.Mutex
+	data journalFile
+}
+
+func NewChangeJournal(path string) *ChangeJournal {
+	return &ChangeJournal{path: path}
+}
+
+// Load читает журнал; отсутствие файла начинает новый журнал с новой эпохой. Эпоха сразу
+// записывается на диск: иначе после перезапуска без изменений она сменится, и выданные
+// токены зря потребуют полной синхронизации.
+func (j *ChangeJournal) Load() error {
+	content, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		epoch, err := randomHex(8)
+		if err != nil {
+			return err
+		}
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.data = journalFile{Epoch: epoch, Entries: []JournalEntry{}}
+		return j.save()
+	}
+	if err != nil {
+		return err
+	}
+
+	var data journalFile
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.data = data
+	j.mu.Unlock()
+	return nil
+}
+
+// Record дописывает изменения индекса; подходит для VideoIndex.OnChange
+func (j *ChangeJournal) Record(changes []VideoChange) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		j.data.Seq++
+		j.data.Entries = append(j.data.Entries, JournalEntry{
+			Seq:    j.data.Seq,
+			Kind:   change.Kind,
+			ID:     change.ID,
+			Folder: change.Folder,
+			Time:   now,
+		})
+	}
+	if extra := len(j.data.Entries) - maxJournalEntries; extra > 0 {
+		j.data.Floor = j.data.Entries[extra-1].Seq
+		j.data.Entries = append([]JournalEntry(nil), j.data.Entries[extra:]...)
+	}
+
+	if err := j.save(); err != nil {
+		log.Printf("❌ Failed to save change journal: %v", err)
+	}
+}
+
+// Token - токен текущего состояния; его берут вместе с полным списком видео
+func (j *ChangeJournal) Token() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.token(j.data.Seq)
+}
+
+// Since сворачивает записи журнала после token, не более limit видео за раз. Видео, добавленное и удалённое
+// внутри окна, в ответ не попадает, а удалённое и появившееся снова считается изменённым.
+func (j *ChangeJournal) Since(token string, limit int) (*ChangeSet, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	seq, err := j.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	type state struct{ first, last VideoChangeKind }
+	states := map[string]*state{}
+	var order []string
+	last := seq
+	for _, e := range j.data.Entries {
+		if e.Seq <= seq {
+			continue
+		}
+		if st, ok := states[e.ID]; ok {
+			st.last = e.Kind
+		} else {
+			// limit считает видео, а не записи журнала
+			if limit > 0 && len(order) >= limit {
+				break
+			}
+			states[e.ID] = &state{first: e.Kind, last: e.Kind}
+			order = append(order, e.ID)
+		}
+		last = e.Seq
+	}
+
+	set := &ChangeSet{
+		Added:   []string{},
+		Updated: []string{},
+		Deleted: []string{},
+		Token:   j.token(last),
+		More:    last < j.data.Seq,
+	}
+	for _, id := range order {
+		st := states[id]
+		switch {
+		case st.last == VideoRemoved && st.first == VideoAdded:
+		case st.last == VideoRemoved:
+			set.Deleted = append(set.Deleted, id)
+		case st.first == VideoAdded:
+			set.Added = append(set.Added, id)
+		default:
+			set.Updated = append(set.Updated, id)
+		}
+	}
+	return set, nil
+}
+
+func (j *ChangeJournal) token(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(j.data.Epoch + "." + strconv.FormatUint(seq, 10)))
+}
+
+func (j *ChangeJournal) parseToken(token string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidChangeToken
+	}
+	epoch, num, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return 0, ErrInvalidChangeToken
+	}
+	seq, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidChangeToken
+	}
+	// токен от прежнего журнала или из будущего тоже требует полной синхронизации
+	if epoch != j.data.Epoch || seq < j.data.Floor || seq > j.data.Seq {
+		return 0, ErrChangeTokenExpired
+	}
+	return seq, nil
+}
+
+func (j *ChangeJournal) save() error {
+	bytes, err := json.Marshal(j.data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(j.path, bytes, 0644)
+}
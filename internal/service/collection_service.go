@@ -0,0 +1,316 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafov/m3u8"
+	"mediafs/internal/entity"
+)
+
+const maxCollectionNameLen = 256
+
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrInvalidCollection  = errors.New("invalid collection")
+)
+
+// Collection - именованный упорядоченный набор видео (сериал, список просмотра).
+// Видео хранятся по постоянным ID, поэтому переименование папок коллекцию не ломает.
+type Collection struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Videos      []string         `json:"videos"`
+	Cover       *CollectionCover `json:"cover,omitempty"`
+	CreatedBy   string           `json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CollectionCover - обложка коллекции: ключевой кадр одного из её видео
+type CollectionCover struct {
+	Video string `json:"video"`
+	Frame string `json:"frame"`
+}
+
+// CollectionPatch - частичное изменение коллекции. Videos заменяет состав и порядок целиком
+// (принимаются имена папок и ID), а cover с пустым video убирает обложку.
+type CollectionPatch struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"description"`
+	Videos      *[]string        `json:"videos"`
+	Cover       *CollectionCover `json:"cover"`
+}
+
+// CollectionPart - сегменты одного видео коллекции для склеенного плейлиста
+type CollectionPart struct {
+	VideoID  string
+	Segments []*m3u8.MediaSegment
+}
+
+type CollectionService struct {
+	path        string
+	index       *VideoIndex
+	mu          sync.Mutex
+	collections map[string]*Collection
+}
+
+func NewCollectionService(path string, index *VideoIndex) *CollectionService {
+	return &CollectionService{
+		path:        path,
+		index:       index,
+		collections: map[string]*Collection{},
+	}
+}
+
+// Load читает файл коллекций; отсутствие файла означает, что коллекций ещё нет
+func (s *CollectionService) Load() error {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	collections := map[string]*Collection{}
+	if err := json.Unmarshal(content, &collections); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.collections = collections
+	s.mu.Unlock()
+	return nil
+}
+
+// List возвращает коллекции, отсортированные по названию
+func (s *CollectionService) List() []Collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Collection, 0, len(s.collections))
+	for _, col := range s.collections {
+		list = append(list, col.copy())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list
+}
+
+func (s *CollectionService) Get(id string) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	col, ok := s.collections[id]
+	if !ok {
+		return nil, ErrCollectionNotFound
+	}
+	copied := col.copy()
+	return &copied, nil
+}
+
+// Create создаёт коллекцию; название обязательно, остальные поля - как в Update
+func (s *CollectionService) Create(patch CollectionPatch, createdBy string) (*Collection, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	col := &Collection{
+		ID:        id,
+		Videos:    []string{},
+		CreatedBy: strings.Clone(createdBy),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if patch.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidCollection)
+	}
+	if err := s.apply(col, patch); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collections[id] = col
+	if err := s.save(); err != nil {
+		delete(s.collections, id)
+		return nil, err
+	}
+	copied := col.copy()
+	return &copied, nil
+}
+
+func (s *CollectionService) Update(id string, patch CollectionPatch) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.collections[id]
+	if !ok {
+		return nil, ErrCollectionNotFound
+	}
+
+	updated := current.copy()
+	if err := s.apply(&updated, patch); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+
+	s.collections[id] = &updated
+	if err := s.save(); err != nil {
+		s.collections[id] = current
+		return nil, err
+	}
+	copied := updated.copy()
+	return &copied, nil
+}
+
+func (s *CollectionService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	col, ok := s.collections[id]
+	if !ok {
+		return ErrCollectionNotFound
+	}
+	delete(s.collections, id)
+	if err := s.save(); err != nil {
+		s.collections[id] = col
+		return err
+	}
+	return nil
+}
+
+// Parts возвращает сегменты видео коллекции по порядку; удалённые видео пропускаются
+func (s *CollectionService) Parts(id string) ([]CollectionPart, error) {
+	col, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]CollectionPart, 0, len(col.Videos))
+	for _, videoID := range col.Videos {
+		folder, ok := s.index.Resolve(videoID)
+		if !ok {
+			continue
+		}
+		playlist := entity.NewMediaInfo(s.index.baseDir, folder).Playlist()
+		if playlist == nil {
+			continue
+		}
+		segments, err := playlist.MediaSegments()
+		if err != nil || len(segments) == 0 {
+			continue
+		}
+		parts = append(parts, CollectionPart{VideoID: videoID, Segments: segments})
+	}
+	return parts, nil
+}
+
+// apply проверяет и применяет patch; видео и обложка сверяются с библиотекой
+func (s *CollectionService) apply(col *Collection, patch CollectionPatch) error {
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if name == "" || len(name) > maxCollectionNameLen {
+			return fmt.Errorf("%w: name must be 1-%d bytes", ErrInvalidCollection, maxCollectionNameLen)
+		}
+		col.Name = name
+	}
+	if patch.Description != nil {
+		if len(*patch.Description) > maxDescriptionLen {
+			return fmt.Errorf("%w: description is longer than %d bytes", ErrInvalidCollection, maxDescriptionLen)
+		}
+		col.Description = *patch.Description
+	}
+
+	if patch.Videos != nil {
+		videos := make([]string, 0, len(*patch.Videos))
+		for _, key := range *patch.Videos {
+			videoID, ok := s.videoID(key)
+			if !ok {
+				return fmt.Errorf("%w: video %q not found", ErrInvalidCollection, key)
+			}
+			if !slices.Contains(videos, videoID) {
+				videos = append(videos, videoID)
+			}
+		}
+		col.Videos = videos
+	}
+
+	switch {
+	case patch.Cover != nil && patch.Cover.Video == "":
+		col.Cover = nil
+	case patch.Cover != nil:
+		cover, err := s.cover(*patch.Cover)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(col.Videos, cover.Video) {
+			return fmt.Errorf("%w: cover must come from a video in the collection", ErrInvalidCollection)
+		}
+		col.Cover = cover
+	case col.Cover != nil && !slices.Contains(col.Videos, col.Cover.Video):
+		// видео с обложкой убрали из коллекции - обложка уходит вместе с ним
+		col.Cover = nil
+	}
+	return nil
+}
+
+// cover проверяет, что кадр есть среди ключевых кадров видео
+func (s *CollectionService) cover(c CollectionCover) (*CollectionCover, error) {
+	folder, ok := s.index.Resolve(c.Video)
+	if !ok {
+		return nil, fmt.Errorf("%w: cover video %q not found", ErrInvalidCollection, c.Video)
+	}
+	videoID, _ := s.videoID(folder)
+
+	frame := filepath.Base(c.Frame)
+	info, err := os.Stat(filepath.Join(s.index.baseDir, folder, "keyframes", frame))
+	if c.Frame == "" || err != nil || info.IsDir() {
+		return nil, fmt.Errorf("%w: keyframe %q not found", ErrInvalidCollection, c.Frame)
+	}
+	return &CollectionCover{Video: videoID, Frame: frame}, nil
+}
+
+// videoID переводит имя папки или ID в постоянный ID видео
+func (s *CollectionService) videoID(key string) (string, bool) {
+	folder, ok := s.index.Resolve(key)
+	if !ok {
+		return "", false
+	}
+	entry, ok := s.index.Get(folder)
+	if !ok {
+		return "", false
+	}
+	return entry.ID, true
+}
+
+func (s *CollectionService) save() error {
+	bytes, err := json.MarshalIndent(s.collections, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bytes, 0644)
+}
+
+func (c *Collection) copy() Collection {
+	copied := *c
+	copied.Videos = slices.Clone(c.Videos)
+	if c.Cover != nil {
+		cover := *c.Cover
+		copied.Cover = &cover
+	}
+	return copied
+}
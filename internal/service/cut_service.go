@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"github.com/grafov/m3u8"
+	"mediafs/internal/entity"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,13 +19,27 @@ func NewCutService(baseDir string) *CutService {
 	return &CutService{BaseDir: baseDir}
 }
 
+// ErrInvalidPath - путь видео или имя клипа выходят за пределы папки видео
+var ErrInvalidPath = errors.New("invalid path")
+
+// CreateClip сохраняет рядом с playlist.m3u8 плейлист из сегментов [from, to).
+// videoname - путь папки видео относительно BaseDir, может быть вложенным.
 func (s *CutService) CreateClip(videoname string, from, to int, name string) (string, error) {
-	dir := filepath.Join(s.BaseDir, videoname)
+	videoname, ok := entity.CleanVideoPath(videoname)
+	if !ok {
+		return "", fmt.Errorf("%w: video %q", ErrInvalidPath, videoname)
+	}
+	dir := filepath.Join(s.BaseDir, filepath.FromSlash(videoname))
 	srcM3U8 := filepath.Join(dir, "playlist.m3u8")
 
+	name = strings.TrimSuffix(name, ".m3u8")
 	if name == "" {
 		name = fmt.Sprintf("cut_%d_%d_%s", from, to, time.Now().Format("150405"))
 	}
+	// клип пишется только в саму папку видео и не может подменить основной плейлист
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || name == "playlist" {
+		return "", fmt.Errorf("%w: clip name %q", ErrInvalidPath, name)
+	}
 	destM3U8 := filepath.Join(dir, name+".m3u8")
 
 	data, err := os.ReadFile(srcM3U8)
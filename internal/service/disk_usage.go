@@ -0,0 +1,14 @@
+package service
+
+import "errors"
+
+var ErrDiskUsageUnsupported = errors.New("disk usage is not supported on this platform")
+
+// DiskUsage - место на файловой системе, где лежит библиотека, в байтах.
+// Available - сколько может занять непривилегированный процесс; обычно меньше Free.
+type DiskUsage struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+	Used      uint64
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package service
+
+// GetDiskUsage на остальных платформах не поддерживается
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	return nil, ErrDiskUsageUnsupported
+}
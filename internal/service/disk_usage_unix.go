@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd
+
+package service
+
+import "golang.org/x/sys/unix"
+
+// GetDiskUsage возвращает место на файловой системе, содержащей path
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+	block := uint64(st.Bsize)
+	total := uint64(st.Blocks) * block
+	free := uint64(st.Bfree) * block
+	return &DiskUsage{
+		Total:     total,
+		Free:      free,
+		Available: uint64(st.Bavail) * block,
+		Used:      total - free,
+	}, nil
+}
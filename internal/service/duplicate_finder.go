@@ -0,0 +1,335 @@
+package service
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeebo/blake3"
+	"mediafs/internal/entity"
+)
+
+// DefaultDuplicateThreshold - доля общих сегментов, начиная с которой два видео считаются копиями
+const DefaultDuplicateThreshold = 0.9
+
+var ErrInvalidThreshold = errors.New("threshold must be in (0, 1]")
+
+// SegmentFingerprint - хэш содержимого сегмента; размер и время изменения нужны, чтобы
+// не перечитывать сегмент, пока он не изменился
+type SegmentFingerprint struct {
+	URI     string    `json:"uri"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+}
+
+// VideoFingerprint - хэши всех сегментов видео и общий хэш по ним в порядке воспроизведения
+type VideoFingerprint struct {
+	ID       string               `json:"id"`
+	Folder   string               `json:"folder"`
+	Hash     string               `json:"hash"`
+	Bytes    int64                `json:"bytes"`
+	Segments []SegmentFingerprint `json:"segments"`
+}
+
+type DuplicateVideo struct {
+	ID           string
+	Folder       string
+	SegmentCount int
+	Bytes        int64
+}
+
+// DuplicateGroup - видео с одинаковыми или почти одинаковыми наборами сегментов.
+// Similarity - наименьшая доля общих сегментов среди пар, связавших группу.
+type DuplicateGroup struct {
+	Exact       bool
+	Similarity  float64
+	Reclaimable int64
+	Videos      []DuplicateVideo
+}
+
+// DuplicateFinder ищет повторно загруженные записи по хэшам сегментов (blake3).
+// Хэши хранятся в .meta, так что повторный поиск читает только новые и изменённые сегменты.
+type DuplicateFinder struct {
+	baseDir string
+	path    string
+	mu      sync.Mutex
+	prints  map[string]*VideoFingerprint
+	running *fingerprintRun
+}
+
+// fingerprintRun - идущий подсчёт хэшей; параллельные запросы ждут его результата
+type fingerprintRun struct {
+	done   chan struct{}
+	prints []*VideoFingerprint
+	err    error
+}
+
+func NewDuplicateFinder(baseDir, path string) *DuplicateFinder {
+	return &DuplicateFinder{
+		baseDir: baseDir,
+		path:    path,
+		prints:  map[string]*VideoFingerprint{},
+	}
+}
+
+// Load читает сохранённые хэши; отсутствие файла означает, что поиск ещё не запускался
+func (f *DuplicateFinder) Load() error {
+	content, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	prints := map[string]*VideoFingerprint{}
+	if err := json.Unmarshal(content, &prints); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.prints = prints
+	f.mu.Unlock()
+	return nil
+}
+
+// Find группирует видео, у которых доля общих сегментов не меньше threshold
+func (f *DuplicateFinder) Find(entries []VideoEntry, threshold float64) ([]DuplicateGroup, error) {
+	if !(threshold > 0 && threshold <= 1) {
+		return nil, ErrInvalidThreshold
+	}
+
+	prints, err := f.fingerprints(entries)
+	if err != nil {
+		return nil, err
+	}
+	return groupDuplicates(prints, threshold), nil
+}
+
+// fingerprints досчитывает хэши для всех видео и забывает удалённые.
+// Хэширование идёт без блокировки; если подсчёт уже идёт, второй проход не запускается:
+// он читал бы те же сегменты, поэтому вызов ждёт и возвращает результат идущего.
+func (f *DuplicateFinder) fingerprints(entries []VideoEntry) ([]*VideoFingerprint, error) {
+	f.mu.Lock()
+	if run := f.running; run != nil {
+		f.mu.Unlock()
+		<-run.done
+		return run.prints, run.err
+	}
+	run := &fingerprintRun{done: make(chan struct{})}
+	f.running = run
+	known := maps.Clone(f.prints)
+	f.mu.Unlock()
+
+	defer close(run.done)
+	current := make(map[string]*VideoFingerprint, len(entries))
+	result := make([]*VideoFingerprint, 0, len(entries))
+	changed := len(entries) != len(known)
+	for _, entry := range entries {
+		fp, updated, err := f.fingerprint(entry, known[entry.ID])
+		if err != nil {
+			log.Printf("❌ Failed to fingerprint %s: %v", entry.Folder, err)
+			continue
+		}
+		changed = changed || updated
+		current[entry.ID] = fp
+		result = append(result, fp)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = nil
+	if changed {
+		f.prints = current
+		if err := f.save(); err != nil {
+			run.err = err
+			return nil, err
+		}
+	}
+	run.prints = result
+	return result, nil
+}
+
+// fingerprint хэширует сегменты видео, переиспользуя хэши сегментов, размер и время изменения
+// которых не поменялись
+func (f *DuplicateFinder) fingerprint(entry VideoEntry, cached *VideoFingerprint) (*VideoFingerprint, bool, error) {
+	playlist := entity.NewMediaInfo(f.baseDir, entry.Folder).Playlist()
+	if playlist == nil {
+		return nil, false, fmt.Errorf("playlist not found")
+	}
+	segments, err := playlist.MediaSegments()
+	if err != nil {
+		return nil, false, err
+	}
+
+	known := map[string]SegmentFingerprint{}
+	if cached != nil {
+		for _, s := range cached.Segments {
+			known[s.URI] = s
+		}
+	}
+
+	dir := filepath.Dir(playlist.Path)
+	fp := &VideoFingerprint{
+		ID:       entry.ID,
+		Folder:   entry.Folder,
+		Segments: make([]SegmentFingerprint, 0, len(segments)),
+	}
+	updated := cached == nil || cached.Folder != entry.Folder || len(cached.Segments) != len(segments)
+	total := blake3.New()
+	for _, seg := range segments {
+		// сегменты за пределами папки видео не читаем
+		if !filepath.IsLocal(filepath.FromSlash(seg.URI)) {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(seg.URI))
+		stat, err := os.Stat(path)
+		if err != nil {
+			updated = true
+			continue
+		}
+
+		s, ok := known[seg.URI]
+		if !ok || s.Size != stat.Size() || !s.ModTime.Equal(stat.ModTime()) {
+			hash, err := hashFile(path)
+			if err != nil {
+				return nil, false, err
+			}
+			s = SegmentFingerprint{URI: seg.URI, Size: stat.Size(), ModTime: stat.ModTime(), Hash: hash}
+			updated = true
+		}
+		fp.Segments = append(fp.Segments, s)
+		fp.Bytes += s.Size
+		total.Write([]byte(s.Hash))
+	}
+	fp.Hash = hex.EncodeToString(total.Sum(nil)[:16])
+	return fp, updated, nil
+}
+
+// hashFile читает файл потоком, не загружая его в память целиком
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := blake3.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)[:16]), nil
+}
+
+// groupDuplicates сравнивает только видео, у которых есть хотя бы один общий сегмент,
+// и объединяет связанные пары в группы
+func groupDuplicates(prints []*VideoFingerprint, threshold float64) []DuplicateGroup {
+	sets := make([]map[string]bool, len(prints))
+	owners := map[string][]int{}
+	for i, fp := range prints {
+		sets[i] = make(map[string]bool, len(fp.Segments))
+		for _, s := range fp.Segments {
+			if !sets[i][s.Hash] {
+				sets[i][s.Hash] = true
+				owners[s.Hash] = append(owners[s.Hash], i)
+			}
+		}
+	}
+
+	shared := map[[2]int]int{}
+	for _, list := range owners {
+		for a := 0; a < len(list); a++ {
+			for b := a + 1; b < len(list); b++ {
+				shared[[2]int{list[a], list[b]}]++
+			}
+		}
+	}
+
+	parent := make([]int, len(prints))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	similarity := map[int]float64{}
+	for pair, count := range shared {
+		score := float64(count) / float64(max(len(sets[pair[0]]), len(sets[pair[1]])))
+		if score < threshold {
+			continue
+		}
+		a, b := root(pair[0]), root(pair[1])
+		low := score
+		if s, ok := similarity[a]; ok {
+			low = min(low, s)
+		}
+		if a != b {
+			if s, ok := similarity[b]; ok {
+				low = min(low, s)
+			}
+			parent[b] = a
+			delete(similarity, b)
+		}
+		similarity[a] = low
+	}
+
+	members := map[int][]int{}
+	for i := range prints {
+		if _, ok := similarity[root(i)]; ok {
+			members[root(i)] = append(members[root(i)], i)
+		}
+	}
+
+	groups := make([]DuplicateGroup, 0, len(members))
+	for r, list := range members {
+		group := DuplicateGroup{Exact: true, Similarity: similarity[r], Videos: make([]DuplicateVideo, 0, len(list))}
+		var total, largest int64
+		for _, i := range list {
+			fp := prints[i]
+			group.Exact = group.Exact && fp.Hash == prints[list[0]].Hash
+			group.Videos = append(group.Videos, DuplicateVideo{
+				ID:           fp.ID,
+				Folder:       fp.Folder,
+				SegmentCount: len(fp.Segments),
+				Bytes:        fp.Bytes,
+			})
+			total += fp.Bytes
+			largest = max(largest, fp.Bytes)
+		}
+		// одну копию оставляем, остальное место можно освободить
+		group.Reclaimable = total - largest
+		sort.Slice(group.Videos, func(i, j int) bool { return group.Videos[i].Folder < group.Videos[j].Folder })
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Reclaimable != groups[j].Reclaimable {
+			return groups[i].Reclaimable > groups[j].Reclaimable
+		}
+		return groups[i].Videos[0].Folder < groups[j].Videos[0].Folder
+	})
+	return groups
+}
+
+func (f *DuplicateFinder) save() error {
+	bytes, err := json.MarshalIndent(f.prints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, bytes, 0644)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventVideoAdded   EventType = "video.added"
+	EventVideoUpdated EventType = "video.updated"
+	EventVideoRemoved EventType = "video.removed"
+	EventVideoMeta    EventType = "video.meta"
+	EventCutCreated   EventType = "cut.created"
+	EventJobProgress  EventType = "job.progress"
+)
+
+const (
+	eventHistorySize   = 256
+	eventSubscriberBuf = 64
+)
+
+// Event - одно событие ленты изменений; ID растёт монотонно в пределах запуска сервера
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// EventBus раздаёт события подписчикам и помнит последние события, чтобы переподключившийся
+// клиент мог догнать пропущенное по Last-Event-ID. Подписчик, который не успевает читать,
+// отключается: лучше переподключение с догоном, чем блокировка издателей.
+type EventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	subs    map[chan Event]struct{}
+	closed  bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[chan Event]struct{}{}}
+}
+
+// Publish отправляет событие всем подписчикам
+func (b *EventBus) Publish(eventType EventType, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now().UTC(), Data: data}
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// PublishVideoChanges переводит изменения индекса в события video.*; подходит для VideoIndex.OnChange
+func (b *EventBus) PublishVideoChanges(changes []VideoChange) {
+	for _, change := range changes {
+		eventType := EventVideoUpdated
+		switch change.Kind {
+		case VideoAdded:
+			eventType = EventVideoAdded
+		case VideoRemoved:
+			eventType = EventVideoRemoved
+		case VideoMetaSet:
+			eventType = EventVideoMeta
+		}
+		b.Publish(eventType, change)
+	}
+}
+
+// Subscribe возвращает события после lastID из истории и канал новых событий.
+// Канал закрывается при отписке, при остановке шины или если подписчик отстал.
+func (b *EventBus) Subscribe(lastID uint64) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	for _, e := range b.history {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+
+	ch := make(chan Event, eventSubscriberBuf)
+	if b.closed {
+		close(ch)
+		return missed, ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return missed, ch, unsubscribe
+}
+
+// Close отключает всех подписчиков; нужно до остановки HTTP-сервера,
+// иначе открытые потоки событий не дадут ему завершиться
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
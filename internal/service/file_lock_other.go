@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package service
+
+// lockFile на платформах без flock ничего не блокирует: остаётся только проверка Changed
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+
+package service
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile берёт эксклюзивный flock на файл блокировки, создавая его при необходимости.
+// Ждёт, пока блокировку не отпустит другой процесс.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"log"
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWatchDebounce = 3 * time.Second
+	watchPollInterval    = 30 * time.Second
+	watchRecentChanges   = 20
+)
+
+// WatchState - состояние наблюдения за библиотекой и результат последнего обхода
+type WatchState struct {
+	Mode             string        `json:"mode"`
+	Watching         bool          `json:"watching"`
+	WatchedDirs      int           `json:"watched_dirs"`
+	Pending          []string      `json:"pending"`
+	LastScanAt       time.Time     `json:"last_scan_at"`
+	LastScanDuration time.Duration `json:"last_scan_duration"`
+	LastScanReason   string        `json:"last_scan_reason"`
+	Videos           int           `json:"videos"`
+	LastError        string        `json:"last_error,omitempty"`
+	RecentChanges    []VideoChange `json:"recent_changes"`
+}
+
+// LibraryWatcher следит за baseDir и обновляет индекс, когда папки видео появляются,
+// меняются или удаляются. Папка, в которую ещё пишутся сегменты, обрабатывается только
+// после debounce без событий. Где inotify недоступен, библиотека периодически обходится целиком.
+type LibraryWatcher struct {
+	baseDir  string
+	index    *VideoIndex
+	debounce time.Duration
+
+	mu      sync.Mutex
+	state   WatchState
+	pending map[string]time.Time
+	onScan  []func(ScanProgress)
+}
+
+// ScanProgress - начало или завершение обхода библиотеки
+type ScanProgress struct {
+	Job      string `json:"job"`
+	Reason   string `json:"reason"`
+	State    string `json:"state"`
+	Videos   int    `json:"videos,omitempty"`
+	Duration int64  `json:"duration_ms,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func NewLibraryWatcher(baseDir string, index *VideoIndex, debounce time.Duration) *LibraryWatcher {
+	w := &LibraryWatcher{
+		baseDir:  baseDir,
+		index:    index,
+		debounce: debounce,
+		pending:  map[string]time.Time{},
+		state:    WatchState{Mode: "polling", Pending: []string{}, RecentChanges: []VideoChange{}},
+	}
+	index.OnChange(w.record)
+	return w
+}
+
+// Run обходит библиотеку и следит за ней до отмены ctx
+func (w *LibraryWatcher) Run(ctx context.Context) {
+	w.scan("startup", nil)
+
+	err := w.watch(ctx)
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	log.Printf("❌ Library watcher failed, falling back to polling: %v", err)
+	w.setWatching("polling", 0, err)
+	w.poll(ctx)
+}
+
+// OnScan подписывает fn на начало и завершение обходов библиотеки
+func (w *LibraryWatcher) OnScan(fn func(ScanProgress)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onScan = append(w.onScan, fn)
+}
+
+// State возвращает копию текущего состояния
+func (w *LibraryWatcher) State() WatchState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state := w.state
+	state.Pending = make([]string, 0, len(w.pending))
+	for folder := range w.pending {
+		state.Pending = append(state.Pending, folder)
+	}
+	slices.Sort(state.Pending)
+	state.RecentChanges = slices.Clone(w.state.RecentChanges)
+	return state
+}
+
+// poll периодически обходит библиотеку целиком
+func (w *LibraryWatcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.scan("poll", nil)
+		}
+	}
+}
+
+// touch откладывает обработку папки: каждое новое событие в ней сдвигает debounce
+func (w *LibraryWatcher) touch(folder string) {
+	w.mu.Lock()
+	w.pending[folder] = time.Now()
+	w.mu.Unlock()
+}
+
+// flush обходит библиотеку, если какие-то папки не менялись дольше debounce
+func (w *LibraryWatcher) flush() {
+	now := time.Now()
+	var ready []string
+
+	w.mu.Lock()
+	for folder, last := range w.pending {
+		if now.Sub(last) >= w.debounce {
+			ready = append(ready, folder)
+			delete(w.pending, folder)
+		}
+	}
+	w.mu.Unlock()
+
+	if len(ready) > 0 {
+		w.scan("change", ready)
+	}
+}
+
+// scan сбрасывает записи изменённых видео и обходит библиотеку заново
+func (w *LibraryWatcher) scan(reason string, folders []string) {
+	for _, folder := range folders {
+		if video, ok := w.videoFolder(folder); ok {
+			w.index.Invalidate(video)
+		}
+	}
+
+	w.progress(ScanProgress{Job: "scan", Reason: reason, State: "running"})
+	started := time.Now()
+	entries, err := w.index.Sync()
+	elapsed := time.Since(started)
+
+	w.mu.Lock()
+	w.state.LastScanAt = started.UTC()
+	w.state.LastScanDuration = elapsed
+	w.state.LastScanReason = reason
+	w.state.LastError = ""
+	if err == nil {
+		w.state.Videos = len(entries)
+	} else {
+		w.state.LastError = err.Error()
+	}
+	w.mu.Unlock()
+
+	done := ScanProgress{Job: "scan", Reason: reason, State: "done", Videos: len(entries), Duration: elapsed.Milliseconds()}
+	if err != nil {
+		log.Printf("❌ Library scan failed: %v", err)
+		done.State, done.Error = "failed", err.Error()
+	}
+	w.progress(done)
+}
+
+func (w *LibraryWatcher) progress(p ScanProgress) {
+	w.mu.Lock()
+	listeners := slices.Clone(w.onScan)
+	w.mu.Unlock()
+	for _, fn := range listeners {
+		fn(p)
+	}
+}
+
+// videoFolder поднимается от изменённой папки к папке видео, которой она принадлежит
+func (w *LibraryWatcher) videoFolder(folder string) (string, bool) {
+	for folder != "." && folder != "" {
+		if w.index.IsVideo(folder) {
+			return folder, true
+		}
+		folder = path.Dir(folder)
+	}
+	return "", false
+}
+
+func (w *LibraryWatcher) record(changes []VideoChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, change := range changes {
+		log.Printf("🔄 Video %s: %s (%s)", change.Kind, change.Folder, change.ID)
+	}
+	recent := append(slices.Clone(changes), w.state.RecentChanges...)
+	if len(recent) > watchRecentChanges {
+		recent = recent[:watchRecentChanges]
+	}
+	w.state.RecentChanges = recent
+}
+
+func (w *LibraryWatcher) setWatching(mode string, dirs int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state.Mode = mode
+	w.state.Watching = mode != "polling"
+	w.state.WatchedDirs = dirs
+	if err != nil {
+		w.state.LastError = err.Error()
+	}
+}
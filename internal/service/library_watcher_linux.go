@@ -0,0 +1,205 @@
+//go:build linux
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+// inotifyWatcher держит по одному watch на каждую не скрытую папку библиотеки
+type inotifyWatcher struct {
+	fd      int
+	baseDir string
+	watches map[int32]string
+	dirs    map[string]int32
+}
+
+// watch следит за библиотекой через inotify; новые папки добавляются по мере появления,
+// а при переполнении очереди событий библиотека обходится заново целиком
+func (w *LibraryWatcher) watch(ctx context.Context) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	iw := &inotifyWatcher{fd: fd, baseDir: w.baseDir, watches: map[int32]string{}, dirs: map[string]int32{}}
+	if err := iw.addTree(""); err != nil {
+		return err
+	}
+	w.setWatching("inotify", len(iw.dirs), nil)
+	log.Printf("👀 Watching %d folders in %s", len(iw.dirs), w.baseDir)
+
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for ctx.Err() == nil {
+		n, err := unix.Poll(fds, 500)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return err
+		}
+		if n > 0 {
+			if err := w.drain(iw, buf); err != nil {
+				return err
+			}
+			w.setWatching("inotify", len(iw.dirs), nil)
+		}
+		w.flush()
+	}
+	return nil
+}
+
+// drain читает все накопившиеся события и откладывает затронутые папки
+func (w *LibraryWatcher) drain(iw *inotifyWatcher, buf []byte) error {
+	for {
+		n, err := unix.Read(iw.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				log.Printf("❌ Library watcher queue overflow, rescanning")
+				iw.reset()
+				if err := iw.addTree(""); err != nil {
+					return err
+				}
+				w.scan("overflow", nil)
+				continue
+			}
+			w.handle(iw, event.Wd, event.Mask, name)
+		}
+	}
+}
+
+func (w *LibraryWatcher) handle(iw *inotifyWatcher, wd int32, mask uint32, name string) {
+	dir, ok := iw.watches[wd]
+	if !ok {
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		iw.forget(dir)
+		return
+	}
+	if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 {
+		// папку удалили или унесли: изменение видно в родителе
+		w.touch(parentDir(dir))
+		return
+	}
+	// служебные файлы (sidecar, временные файлы атомарной записи) библиотеку не меняют
+	if name == "" || strings.HasPrefix(name, ".") {
+		return
+	}
+
+	rel := path.Join(dir, name)
+	if mask&unix.IN_ISDIR != 0 {
+		switch {
+		case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+			if err := iw.addTree(rel); err != nil {
+				log.Printf("❌ Failed to watch %s: %v", rel, err)
+			}
+			w.touch(rel)
+		case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+			iw.removeTree(rel)
+			w.touch(dir)
+		}
+		return
+	}
+	w.touch(dir)
+}
+
+// addTree ставит watch на папку rel и все её не скрытые подпапки
+func (iw *inotifyWatcher) addTree(rel string) error {
+	root := filepath.Join(iw.baseDir, filepath.FromSlash(rel))
+	return filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// папку могли удалить, пока мы до неё добирались
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != iw.baseDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		dir, err := filepath.Rel(iw.baseDir, p)
+		if err != nil {
+			return err
+		}
+		dir = filepath.ToSlash(dir)
+		if dir == "." {
+			dir = ""
+		}
+		wd, err := unix.InotifyAddWatch(iw.fd, p, inotifyMask)
+		if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENOTDIR) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if old, ok := iw.watches[int32(wd)]; ok && old != dir {
+			delete(iw.dirs, old)
+		}
+		iw.watches[int32(wd)] = dir
+		iw.dirs[dir] = int32(wd)
+		return nil
+	})
+}
+
+// removeTree снимает watch с папки и её подпапок, ушедших из библиотеки
+func (iw *inotifyWatcher) removeTree(rel string) {
+	for dir, wd := range iw.dirs {
+		if dir == rel || strings.HasPrefix(dir, rel+"/") {
+			_, _ = unix.InotifyRmWatch(iw.fd, uint32(wd))
+			delete(iw.dirs, dir)
+			delete(iw.watches, wd)
+		}
+	}
+}
+
+func (iw *inotifyWatcher) forget(dir string) {
+	if wd, ok := iw.dirs[dir]; ok {
+		delete(iw.dirs, dir)
+		delete(iw.watches, wd)
+	}
+}
+
+func (iw *inotifyWatcher) reset() {
+	for _, wd := range iw.dirs {
+		_, _ = unix.InotifyRmWatch(iw.fd, uint32(wd))
+	}
+	iw.watches = map[int32]string{}
+	iw.dirs = map[string]int32{}
+}
+
+func parentDir(dir string) string {
+	parent := path.Dir(dir)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
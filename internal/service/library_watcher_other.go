@@ -0,0 +1,12 @@
+//go:build !linux
+
+package service
+
+import "context"
+
+// watch без inotify: библиотека обходится целиком раз в watchPollInterval
+func (w *LibraryWatcher) watch(ctx context.Context) error {
+	w.setWatching("polling", 0, nil)
+	w.poll(ctx)
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Сколько неудачных попыток с одного IP допускается без задержки
+	ipFreeAttempts = 5
+	// Первая блокировка IP; каждая следующая неудача удваивает её
+	ipBaseLockout = 30 * time.Second
+	ipMaxLockout  = time.Hour
+	// Счётчик IP сбрасывается, если неудач не было дольше этого окна
+	ipFailureWindow = 24 * time.Hour
+
+	// Глобальная защита от перебора с множества адресов
+	globalFailureLimit  = 100
+	globalFailureWindow = time.Minute
+	globalLockout       = 5 * time.Minute
+
+	// Retry-After для попытки, отклонённой из-за ещё не проверенных попыток с того же IP
+	inFlightWait = time.Second
+)
+
+type ipAttempts struct {
+	failures    int
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LockoutEvent описывает наложенную блокировку; IP пустой для глобальной
+type LockoutEvent struct {
+	IP       string
+	Failures int
+	Until    time.Time
+}
+
+// LoginLimiter считает неудачные входы по IP и в целом по серверу и
+// блокирует вход с экспоненциально растущей задержкой.
+// Allow резервирует попытку до её проверки, поэтому одновременная пачка запросов с одного IP
+// не проходит мимо счётчика: в полёте не больше попыток, чем осталось до блокировки.
+// Каждая разрешённая попытка завершается вызовом Fail, Success или Release.
+type LoginLimiter struct {
+	mu             sync.Mutex
+	ips            map[string]*ipAttempts
+	globalFailures []time.Time
+	globalLocked   time.Time
+	onLockout      func(LockoutEvent)
+}
+
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{ips: map[string]*ipAttempts{}}
+}
+
+// OnLockout задаёт обработчик, вызываемый при каждой новой блокировке
+func (l *LoginLimiter) OnLockout(fn func(LockoutEvent)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onLockout = fn
+}
+
+// Allow резервирует попытку входа с ip. Возвращает false и время ожидания, если вход заблокирован
+// или уже проверяются попытки, которые могут исчерпать оставшийся до блокировки запас.
+func (l *LoginLimiter) Allow(ip string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.globalLocked) {
+		return l.globalLocked.Sub(now), false
+	}
+	a, ok := l.ips[ip]
+	if !ok {
+		a = &ipAttempts{}
+		l.ips[strings.Clone(ip)] = a
+	}
+	if now.Before(a.lockedUntil) {
+		return a.lockedUntil.Sub(now), false
+	}
+	// после блокировки разрешаем по одной попытке за раз
+	if a.pending > 0 && a.failures+a.pending >= ipFreeAttempts {
+		return inFlightWait, false
+	}
+	a.pending++
+	return 0, true
+}
+
+// Release снимает резерв попытки, которая закончилась без проверки пароля
+// (например, неверный JSON или запрос второго фактора)
+func (l *LoginLimiter) Release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a, ok := l.ips[ip]; ok && a.pending > 0 {
+		a.pending--
+	}
+}
+
+// Fail регистрирует неудачную попытку и возвращает длительность наложенной блокировки (0 - без блокировки)
+func (l *LoginLimiter) Fail(ip string) time.Duration {
+	l.mu.Lock()
+
+	now := time.Now()
+	l.prune(now)
+
+	a, ok := l.ips[ip]
+	if !ok {
+		a = &ipAttempts{}
+		l.ips[strings.Clone(ip)] = a
+	}
+	if a.pending > 0 {
+		a.pending--
+	}
+	a.failures++
+	a.lastFailure = now
+
+	var events []LockoutEvent
+	lockout := time.Duration(0)
+
+	if a.failures >= ipFreeAttempts {
+		lockout = ipBaseLockout << min(a.failures-ipFreeAttempts, 16)
+		if lockout > ipMaxLockout {
+			lockout = ipMaxLockout
+		}
+		a.lockedUntil = now.Add(lockout)
+		events = append(events, LockoutEvent{IP: ip, Failures: a.failures, Until: a.lockedUntil})
+	}
+
+	l.globalFailures = append(l.globalFailures, now)
+	if len(l.globalFailures) >= globalFailureLimit && !now.Before(l.globalLocked) {
+		l.globalLocked = now.Add(globalLockout)
+		if globalLockout > lockout {
+			lockout = globalLockout
+		}
+		events = append(events, LockoutEvent{Failures: len(l.globalFailures), Until: l.globalLocked})
+	}
+
+	onLockout := l.onLockout
+	l.mu.Unlock()
+
+	for _, e := range events {
+		if e.IP == "" {
+			log.Printf("🔒 Login locked globally until %s after %d failures in %s", e.Until.Format(time.RFC3339), e.Failures, globalFailureWindow)
+		} else {
+			log.Printf("🔒 Login locked for %s until %s after %d failures", e.IP, e.Until.Format(time.RFC3339), e.Failures)
+		}
+		if onLockout != nil {
+			onLockout(e)
+		}
+	}
+	return lockout
+}
+
+// Success сбрасывает счётчик неудач для ip
+func (l *LoginLimiter) Success(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ips, ip)
+}
+
+// prune забывает старые неудачи, чтобы карта не росла бесконечно
+func (l *LoginLimiter) prune(now time.Time) {
+	for ip, a := range l.ips {
+		if a.pending == 0 && now.Sub(a.lastFailure) > ipFailureWindow && now.After(a.lockedUntil) {
+			delete(l.ips, ip)
+		}
+	}
+
+	cutoff := now.Add(-globalFailureWindow)
+	i := 0
+	for i < len(l.globalFailures) && l.globalFailures[i].Before(cutoff) {
+		i++
+	}
+	l.globalFailures = l.globalFailures[i:]
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxProgressPerUser ограничивает историю просмотра одного пользователя; старые записи вытесняются
+const maxProgressPerUser = 1000
+
+// progressFlushInterval - как часто изменения прогресса сбрасываются на диск. Плееры шлют
+// позицию каждые несколько секунд, и переписывать весь файл на каждый запрос незачем.
+const progressFlushInterval = 10 * time.Second
+
+var ErrInvalidProgress = errors.New("invalid progress")
+
+// WatchProgress - место, на котором пользователь остановился в видео, и устройство,
+// с которого оно сохранено
+type WatchProgress struct {
+	VideoID   string    `json:"video_id"`
+	Position  float64   `json:"position"`
+	Finished  bool      `json:"finished"`
+	Device    string    `json:"device,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProgressService хранит прогресс просмотра по пользователям и постоянным ID видео,
+// поэтому продолжить можно на другом устройстве и после переименования папки.
+// Изменения копятся в памяти и записываются Run раз в progressFlushInterval.
+type ProgressService struct {
+	path     string
+	mu       sync.Mutex
+	progress map[string]map[string]*WatchProgress
+	dirty    bool
+}
+
+func NewProgressService(path string) *ProgressService {
+	return &ProgressService{
+		path:     path,
+		progress: map[string]map[string]*WatchProgress{},
+	}
+}
+
+// Load читает файл прогресса; отсутствие файла означает, что никто ещё ничего не смотрел
+func (s *ProgressService) Load() error {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	progress := map[string]map[string]*WatchProgress{}
+	if err := json.Unmarshal(content, &progress); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.progress = progress
+	s.mu.Unlock()
+	return nil
+}
+
+// Save запоминает позицию (в секундах) пользователя в видео
+func (s *ProgressService) Save(principal, videoID string, position float64, finished bool, session *Session) (*WatchProgress, error) {
+	if principal == "" {
+		return nil, fmt.Errorf("%w: no user", ErrInvalidProgress)
+	}
+	if position < 0 || math.IsNaN(position) || math.IsInf(position, 0) {
+		return nil, fmt.Errorf("%w: position must be a non-negative number of seconds", ErrInvalidProgress)
+	}
+
+	p := &WatchProgress{
+		VideoID:   strings.Clone(videoID),
+		Position:  position,
+		Finished:  finished,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if session != nil {
+		p.Device, p.SessionID = session.Device, session.ID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.progress[principal]
+	if !ok {
+		user = map[string]*WatchProgress{}
+		s.progress[strings.Clone(principal)] = user
+	}
+	user[p.VideoID] = p
+	s.trim(user)
+	s.dirty = true
+
+	copied := *p
+	return &copied, nil
+}
+
+// Run сбрасывает накопленные изменения на диск, пока не отменён ctx, и ещё раз при остановке
+func (s *ProgressService) Run(ctx context.Context) {
+	ticker := time.NewTicker(progressFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				log.Printf("❌ Failed to save watch progress: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				log.Printf("❌ Failed to save watch progress: %v", err)
+			}
+		}
+	}
+}
+
+// Flush записывает прогресс, если он менялся после прошлой записи
+func (s *ProgressService) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// All возвращает прогресс пользователя по всем видео: ID видео -> прогресс
+func (s *ProgressService) All(principal string) map[string]WatchProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]WatchProgress, len(s.progress[principal]))
+	for id, p := range s.progress[principal] {
+		result[id] = *p
+	}
+	return result
+}
+
+// Recent - недосмотренные видео пользователя, последние просмотренные первыми
+func (s *ProgressService) Recent(principal string) []WatchProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]WatchProgress, 0)
+	for _, p := range s.progress[principal] {
+		if !p.Finished && p.Position > 0 {
+			list = append(list, *p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt.After(list[j].UpdatedAt)
+	})
+	return list
+}
+
+// trim вытесняет самые давние записи сверх лимита
+func (s *ProgressService) trim(user map[string]*WatchProgress) {
+	if len(user) <= maxProgressPerUser {
+		return
+	}
+	list := make([]*WatchProgress, 0, len(user))
+	for _, p := range user {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt.Before(list[j].UpdatedAt)
+	})
+	for _, p := range list[:len(list)-maxProgressPerUser] {
+		delete(user, p.VideoID)
+	}
+}
+
+func (s *ProgressService) save() error {
+	bytes, err := json.MarshalIndent(s.progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bytes, 0600)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope - право на группу маршрутов; API-ключи получают явный список, пользователи - по роли
+type Scope string
+
+const (
+	ScopeAll          Scope = "*"
+	ScopeVideosRead   Scope = "videos:read"
+	ScopeVideosWrite  Scope = "videos:write"
+	ScopeVideosDelete Scope = "videos:delete"
+	ScopeCutWrite     Scope = "cut:write"
+	ScopeAuditRead    Scope = "audit:read"
+	ScopeSharesWrite  Scope = "shares:write"
+)
+
+var knownScopes = []Scope{ScopeVideosRead, ScopeVideosWrite, ScopeVideosDelete, ScopeCutWrite, ScopeAuditRead, ScopeSharesWrite}
+
+var roleScopes = map[Role][]Scope{
+	RoleViewer: {ScopeVideosRead},
+	RoleEditor: {ScopeVideosRead, ScopeVideosWrite, ScopeVideosDelete, ScopeCutWrite, ScopeSharesWrite},
+	RoleAdmin:  {ScopeAll},
+}
+
+// ParseScopes разбирает список вида "videos:read,cut:write"
+func ParseScopes(csv string) ([]Scope, error) {
+	scopes := make([]Scope, 0)
+	for _, part := range strings.Split(csv, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		scope := Scope(part)
+		if !isKnownScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", part)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// HasScope сообщает, выдаёт ли роль указанное право
+func (r Role) HasScope(scope Scope) bool {
+	return scopesContain(roleScopes[r], scope)
+}
+
+func scopesContain(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownScope(scope Scope) bool {
+	if scope == ScopeAll {
+		return true
+	}
+	for _, s := range knownScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
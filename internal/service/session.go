@@ -0,0 +1,230 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval - как часто изменение LastSeen сбрасывается на диск
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session - одно устройство, на котором выполнен вход. Сами токены не хранятся, только их хэши.
+type Session struct {
+	ID               string    `json:"id"`
+	Username         string    `json:"username"`
+	Device           string    `json:"device,omitempty"`
+	UserAgent        string    `json:"user_agent,omitempty"`
+	TokenHash        string    `json:"token_hash"`
+	RefreshHash      string    `json:"refresh_hash"`
+	CreatedAt        time.Time `json:"created_at"`
+	LastSeen         time.Time `json:"last_seen"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// TokenPair - выданные при входе или обновлении токены
+type TokenPair struct {
+	SessionID        string    `json:"sessionId"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// CreateSession открывает новую сессию пользователя, не трогая остальные его устройства
+func (a *AuthService) CreateSession(username, device, userAgent string) (TokenPair, error) {
+	a.lock()
+	defer a.mu.Unlock()
+
+	var pair TokenPair
+	err := a.update(func(d *AuthData) error {
+		u, ok := d.Users[username]
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		now := time.Now()
+		d.pruneSessions(now)
+
+		s := &Session{
+			ID:        uuid.NewString(),
+			Username:  username,
+			Device:    strings.Clone(device),
+			UserAgent: strings.Clone(userAgent),
+			CreatedAt: now,
+			LastSeen:  now,
+		}
+		pair = a.issueTokens(s, now)
+		d.Sessions[s.ID] = s
+		u.LastAuthTime = now
+		return nil
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// Refresh обменивает действующий refresh-токен на новую пару; старая пара перестаёт работать
+func (a *AuthService) Refresh(refreshToken string) (TokenPair, error) {
+	if refreshToken == "" {
+		return TokenPair{}, ErrInvalidToken
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+
+	var pair TokenPair
+	err := a.update(func(d *AuthData) error {
+		now := time.Now()
+		s := d.findSession(hashToken(refreshToken), func(s *Session) string { return s.RefreshHash })
+		if s == nil || now.After(s.RefreshExpiresAt) {
+			return ErrInvalidToken
+		}
+		pair = a.issueTokens(s, now)
+		s.LastSeen = now
+		return nil
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// CheckToken возвращает копии пользователя и сессии, которым принадлежит непросроченный токен
+func (a *AuthService) CheckToken(token string) (*User, *Session, bool) {
+	if token == "" {
+		return nil, nil, false
+	}
+
+	a.lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	s := a.data.findSession(hashToken(token), func(s *Session) string { return s.TokenHash })
+	if s == nil {
+		return nil, nil, false
+	}
+	u, ok := a.data.Users[s.Username]
+	if !ok || now.After(s.ExpiresAt) {
+		return nil, nil, false
+	}
+
+	if now.Sub(s.LastSeen) > sessionTouchInterval {
+		err := a.update(func(d *AuthData) error {
+			if current, ok := d.Sessions[s.ID]; ok {
+				current.LastSeen = now
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("❌ Failed to update session last seen: %v", err)
+		}
+	}
+
+	// update мог перечитать данные: сессию или пользователя могли удалить из CLI
+	current, ok := a.data.Sessions[s.ID]
+	if !ok {
+		return nil, nil, false
+	}
+	u, ok = a.data.Users[current.Username]
+	if !ok {
+		return nil, nil, false
+	}
+	user, session := *u, *current
+	return &user, &session, true
+}
+
+// Sessions возвращает сессии пользователя (или все, если username пустой), новые первыми
+func (a *AuthService) Sessions(username string) []Session {
+	a.lock()
+	defer a.mu.Unlock()
+
+	sessions := make([]Session, 0)
+	for _, s := range a.data.Sessions {
+		if username == "" || s.Username == username {
+			sessions = append(sessions, *s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeen.After(sessions[j].LastSeen) })
+	return sessions
+}
+
+// RevokeSession завершает сессию; если username непустой, сессия должна принадлежать ему
+func (a *AuthService) RevokeSession(id, username string) error {
+	a.lock()
+	defer a.mu.Unlock()
+
+	return a.update(func(d *AuthData) error {
+		s, ok := d.Sessions[id]
+		if !ok || (username != "" && s.Username != username) {
+			return ErrSessionNotFound
+		}
+		delete(d.Sessions, id)
+		return nil
+	})
+}
+
+func (a *AuthService) issueTokens(s *Session, now time.Time) TokenPair {
+	token := uuid.NewString()
+	refresh := uuid.NewString()
+
+	s.TokenHash = hashToken(token)
+	s.ExpiresAt = now.Add(a.tokenTTL)
+	s.RefreshHash = hashToken(refresh)
+	s.RefreshExpiresAt = now.Add(a.refreshTTL)
+
+	return TokenPair{
+		SessionID:        s.ID,
+		Token:            token,
+		ExpiresAt:        s.ExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: s.RefreshExpiresAt,
+	}
+}
+
+// findSession ищет сессию, у которой хэш, выбранный field, совпадает с hash
+func (d *AuthData) findSession(hash string, field func(*Session) string) *Session {
+	for _, s := range d.Sessions {
+		if hashEqual(field(s), hash) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (d *AuthData) revokeUserSessions(username string) {
+	for id, s := range d.Sessions {
+		if s.Username == username {
+			delete(d.Sessions, id)
+		}
+	}
+}
+
+// pruneSessions удаляет сессии, которые уже нельзя продлить
+func (d *AuthData) pruneSessions(now time.Time) {
+	for id, s := range d.Sessions {
+		if now.After(s.RefreshExpiresAt) {
+			delete(d.Sessions, id)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashEqual(stored, given string) bool {
+	return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
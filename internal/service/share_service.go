@@ -0,0 +1,258 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DefaultShareTTL = 24 * time.Hour
+
+var (
+	ErrShareNotFound  = errors.New("share not found")
+	ErrShareExpired   = errors.New("share expired")
+	ErrShareExhausted = errors.New("share view limit reached")
+)
+
+// Share - анонимная ссылка на одно видео. Сам токен (часть публичного адреса /s/<token>/)
+// показывается один раз при создании, хранится только его SHA-256. ID - открытый идентификатор
+// для списка и удаления ссылок. Видео хранится по постоянному ID, поэтому ссылка
+// переживает переименование папки и не перейдёт на другое видео с тем же именем.
+type Share struct {
+	ID           string    `json:"id"`
+	TokenHash    string    `json:"token_hash"`
+	VideoID      string    `json:"video_id"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxViews     int       `json:"max_views,omitempty"`
+	Views        int       `json:"views"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+
+	// Поля старого формата: открытый токен и папка видео, читаются только миграцией в Load
+	Token string `json:"token,omitempty"`
+	Video string `json:"video,omitempty"`
+}
+
+func (s *Share) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// ShareService хранит ссылки по хэшу токена: по нему ищется ссылка на каждый запрос к /s/
+type ShareService struct {
+	path   string
+	index  *VideoIndex
+	mu     sync.Mutex
+	shares map[string]*Share
+}
+
+func NewShareService(path string, index *VideoIndex) *ShareService {
+	return &ShareService{
+		path:   path,
+		index:  index,
+		shares: map[string]*Share{},
+	}
+}
+
+// Load читает файл ссылок; отсутствие файла означает, что ссылок ещё нет
+func (s *ShareService) Load() error {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stored := map[string]*Share{}
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return err
+	}
+
+	shares := make(map[string]*Share, len(stored))
+	migrated := false
+	for _, sh := range stored {
+		if sh.Token != "" {
+			id, err := randomHex(6)
+			if err != nil {
+				return err
+			}
+			sh.ID, sh.TokenHash, sh.Token = id, hashToken(sh.Token), ""
+			migrated = true
+		}
+		if sh.Video != "" {
+			entry, ok := s.index.Get(sh.Video)
+			migrated = true
+			if !ok {
+				log.Printf("⚠️ Dropping share %s: video %q not found", sh.ID, sh.Video)
+				continue
+			}
+			sh.VideoID, sh.Video = entry.ID, ""
+		}
+		shares[sh.TokenHash] = sh
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = shares
+	if migrated {
+		return s.save()
+	}
+	return nil
+}
+
+// Create сохраняет новую ссылку и возвращает её токен - больше его узнать нельзя.
+// Строки копируются: Fiber отдаёт параметры запроса без копирования, а ссылка живёт дольше запроса.
+func (s *ShareService) Create(videoID, createdBy string, ttl time.Duration, maxViews int, password string) (string, *Share, error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(6)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	share := &Share{
+		ID:        id,
+		TokenHash: hashToken(token),
+		VideoID:   strings.Clone(videoID),
+		CreatedBy: strings.Clone(createdBy),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxViews:  maxViews,
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", nil, err
+		}
+		share.PasswordHash = string(hash)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	s.shares[share.TokenHash] = share
+	if err := s.save(); err != nil {
+		delete(s.shares, share.TokenHash)
+		return "", nil, err
+	}
+	copied := *share
+	return token, &copied, nil
+}
+
+// List возвращает ссылки на видео, новые первыми
+func (s *ShareService) List(videoID string) []Share {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shares := make([]Share, 0)
+	for _, sh := range s.shares {
+		if sh.VideoID == videoID {
+			shares = append(shares, *sh)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares
+}
+
+// Delete удаляет ссылку на видео по её открытому ID
+func (s *ShareService) Delete(videoID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, sh := range s.shares {
+		if sh.ID == id && sh.VideoID == videoID {
+			delete(s.shares, key)
+			return s.save()
+		}
+	}
+	return ErrShareNotFound
+}
+
+// Get возвращает непросроченную ссылку. Лимит просмотров проверяет только RecordView,
+// чтобы уже начатый просмотр мог догрузить сегменты.
+func (s *ShareService) Get(token string) (*Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh, ok := s.shares[hashToken(token)]
+	if !ok {
+		return nil, ErrShareNotFound
+	}
+	if time.Now().After(sh.ExpiresAt) {
+		return nil, ErrShareExpired
+	}
+	copied := *sh
+	return &copied, nil
+}
+
+// Folder находит текущую папку видео ссылки
+func (s *ShareService) Folder(sh *Share) (string, bool) {
+	return s.index.Resolve(sh.VideoID)
+}
+
+// CheckPassword сверяет пароль ссылки; ссылки без пароля проходят всегда
+func (s *ShareService) CheckPassword(token, password string) bool {
+	s.mu.Lock()
+	sh, ok := s.shares[hashToken(token)]
+	var hash string
+	if ok {
+		hash = sh.PasswordHash
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	return hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// RecordView засчитывает просмотр, если лимит ещё не исчерпан
+func (s *ShareService) RecordView(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh, ok := s.shares[hashToken(token)]
+	if !ok {
+		return ErrShareNotFound
+	}
+	if time.Now().After(sh.ExpiresAt) {
+		return ErrShareExpired
+	}
+	if sh.MaxViews > 0 && sh.Views >= sh.MaxViews {
+		return ErrShareExhausted
+	}
+	sh.Views++
+	if err := s.save(); err != nil {
+		sh.Views--
+		return err
+	}
+	return nil
+}
+
+// prune удаляет ссылки, истёкшие больше суток назад
+func (s *ShareService) prune(now time.Time) {
+	for key, sh := range s.shares {
+		if now.Sub(sh.ExpiresAt) > 24*time.Hour {
+			delete(s.shares, key)
+		}
+	}
+}
+
+func (s *ShareService) save() error {
+	bytes, err := json.MarshalIndent(s.shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, bytes, 0600)
+}
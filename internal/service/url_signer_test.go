@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyRejectsPathsLeavingScope(t *testing.T) {
+	signer := NewURLSigner([]byte("secret"), time.Hour)
+	q, _ := signer.Sign("admin", "/videos/IDA/")
+	verify := func(path string) bool {
+		_, ok := signer.Verify(path, q.Get(SignParamUser), q.Get(SignParamScope), q.Get(SignParamExpires), q.Get(SignParamSig))
+		return ok
+	}
+
+	for _, path := range []string{"/videos/IDA/playlist.m3u8", "/videos/IDA/seg0.ts"} {
+		if !verify(path) {
+			t.Errorf("Verify(%q) = false, want true", path)
+		}
+	}
+	for _, path := range []string{
+		"/videos/IDA/..%2Fsecret/seg0.ts",
+		"/videos/IDA/../secret/seg0.ts",
+		"/videos/IDA/%2E%2E/secret/seg0.ts",
+		"/videos/IDA/x%2F..%2F..%2Fsecret/seg0.ts",
+		"/videos/IDB/seg0.ts",
+	} {
+		if verify(path) {
+			t.Errorf("Verify(%q) = true, want false", path)
+		}
+	}
+}
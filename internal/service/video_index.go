@@ -0,0 +1,492 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mediafs/internal/entity"
+)
+
+// probeVersion растёт, когда меняется состав вычисляемых метаданных: записи старой версии
+// пересчитываются при следующем обращении
+const probeVersion = 2
+
+// VideoEntry - закэшированные метаданные одного видео
+type VideoEntry struct {
+	ID                 string             `json:"id"`
+	PlaylistID         string             `json:"playlist_id"`
+	Folder             string             `json:"folder"`
+	FolderModTime      time.Time          `json:"folder_mod_time"`
+	KeyframesModTime   time.Time          `json:"keyframes_mod_time"`
+	Duration           int                `json:"duration"`
+	Resolution         string             `json:"resolution,omitempty"`
+	SizeMB             int                `json:"size_mb"`
+	SegmentCount       int                `json:"segment_count"`
+	AvgSegmentDuration float64            `json:"avg_segment_duration"`
+	Streams            *entity.StreamInfo `json:"streams,omitempty"`
+	FrameHashes        []uint64           `json:"frame_hashes,omitempty"`
+	ProbeVersion       int                `json:"probe_version"`
+	IndexedAt          time.Time          `json:"indexed_at"`
+
+	// DerivedID - sidecar у папки ещё нет, ID вычислен из пути (см. derivedID)
+	DerivedID bool `json:"derived_id,omitempty"`
+
+	// CreatedAt и Meta читаются из sidecar при каждом обращении и в индексе не хранятся
+	CreatedAt time.Time `json:"-"`
+	Meta      VideoMeta `json:"-"`
+}
+
+// VideoIndex хранит метаданные видео в .meta, чтобы список не разбирал сегменты на каждый запрос.
+// Запись ищется по Playlist.ID() (путь + содержимое плейлиста) и сверяется со временем
+// изменения папки, поэтому пересчитывается только при изменении плейлиста или состава папки.
+type VideoIndex struct {
+	baseDir   string
+	path      string
+	mu        sync.Mutex
+	entries   map[string]*VideoEntry
+	listeners []func([]VideoChange)
+}
+
+type VideoChangeKind string
+
+const (
+	VideoAdded   VideoChangeKind = "added"
+	VideoUpdated VideoChangeKind = "updated"
+	VideoRemoved VideoChangeKind = "removed"
+	VideoMetaSet VideoChangeKind = "meta"
+)
+
+// VideoChange - появление, пересчёт, исчезновение видео в индексе или смена его метаданных
+type VideoChange struct {
+	Kind   VideoChangeKind `json:"kind"`
+	ID     string          `json:"id"`
+	Folder string          `json:"folder"`
+}
+
+func NewVideoIndex(baseDir, path string) *VideoIndex {
+	return &VideoIndex{
+		baseDir: baseDir,
+		path:    path,
+		entries: map[string]*VideoEntry{},
+	}
+}
+
+// Load читает индекс; отсутствие файла означает, что индекс ещё не построен
+func (ix *VideoIndex) Load() error {
+	content, err := os.ReadFile(ix.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := map[string]*VideoEntry{}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	ix.entries = entries
+	ix.mu.Unlock()
+	return nil
+}
+
+// List возвращает все видео в baseDir и во вложенных папках, досчитывая устаревшие записи
+// и забывая удалённые папки. Папка с playlist.m3u8 считается видео, внутрь неё обход не идёт.
+// List только читает папки видео: sidecar создают Sync и UpdateMeta.
+func (ix *VideoIndex) List() ([]VideoEntry, error) {
+	return ix.list(false)
+}
+
+// Sync обходит библиотеку как List и записывает sidecar с ID тем видео, у которых его ещё нет.
+// Вызывается наблюдателем за библиотекой и переиндексацией.
+func (ix *VideoIndex) Sync() ([]VideoEntry, error) {
+	return ix.list(true)
+}
+
+func (ix *VideoIndex) list(sync bool) ([]VideoEntry, error) {
+	var found []*VideoEntry
+	changed := false
+
+	err := filepath.WalkDir(ix.baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == ix.baseDir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(ix.baseDir, path)
+		if err != nil {
+			return err
+		}
+		entry, probed := ix.lookup(filepath.ToSlash(rel))
+		if entry == nil {
+			return nil
+		}
+		changed = changed || probed
+		found = append(found, entry)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changed = resolveIDConflicts(found) || changed
+	if sync {
+		changed = ix.writeSidecars(found) || changed
+	}
+	result := make([]VideoEntry, 0, len(found))
+	current := make(map[string]*VideoEntry, len(found))
+	for _, entry := range found {
+		current[entry.PlaylistID] = entry
+		result = append(result, *entry)
+	}
+
+	ix.mu.Lock()
+	changes := diffEntries(ix.entries, current)
+	if changed || len(current) != len(ix.entries) {
+		ix.entries = current
+		if err := ix.save(); err != nil {
+			log.Printf("❌ Failed to save video index: %v", err)
+		}
+	}
+	ix.mu.Unlock()
+
+	ix.notify(changes)
+	return result, nil
+}
+
+// Get возвращает метаданные одного видео, при необходимости обновляя его запись
+func (ix *VideoIndex) Get(folder string) (*VideoEntry, bool) {
+	entry, probed := ix.lookup(folder)
+	if entry == nil {
+		return nil, false
+	}
+	if probed {
+		ix.mu.Lock()
+		change := VideoChange{Kind: VideoAdded, ID: entry.ID, Folder: entry.Folder}
+		for key, e := range ix.entries {
+			if e.ID == entry.ID {
+				change.Kind = VideoUpdated
+				delete(ix.entries, key)
+			}
+		}
+		ix.entries[entry.PlaylistID] = entry
+		if err := ix.save(); err != nil {
+			log.Printf("❌ Failed to save video index: %v", err)
+		}
+		ix.mu.Unlock()
+		ix.notify([]VideoChange{change})
+	}
+	copied := *entry
+	return &copied, true
+}
+
+// IsVideo сообщает, что путь ведёт к папке видео внутри baseDir
+func (ix *VideoIndex) IsVideo(folder string) bool {
+	folder, ok := entity.CleanVideoPath(folder)
+	return ok && entity.NewMediaInfo(ix.baseDir, folder).Playlist() != nil
+}
+
+// Invalidate помечает записи папки устаревшими, чтобы следующий обход посчитал её заново.
+// Нужно, когда файлы внутри папки переписаны на месте и время изменения папки не сдвинулось.
+func (ix *VideoIndex) Invalidate(folder string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, e := range ix.entries {
+		if e.Folder == folder {
+			stale := *e
+			stale.FolderModTime = time.Time{}
+			ix.entries[key] = &stale
+		}
+	}
+}
+
+// Remove забывает записи удалённой папки и сообщает подписчикам об удалении,
+// не дожидаясь следующего обхода библиотеки
+func (ix *VideoIndex) Remove(folder string) {
+	ix.mu.Lock()
+	var changes []VideoChange
+	for key, e := range ix.entries {
+		if e.Folder == folder {
+			changes = append(changes, VideoChange{Kind: VideoRemoved, ID: e.ID, Folder: e.Folder})
+			delete(ix.entries, key)
+		}
+	}
+	if len(changes) > 0 {
+		if err := ix.save(); err != nil {
+			log.Printf("❌ Failed to save video index: %v", err)
+		}
+	}
+	ix.mu.Unlock()
+	ix.notify(changes)
+}
+
+// OnChange подписывает fn на изменения индекса; fn вызывается без блокировки индекса
+func (ix *VideoIndex) OnChange(fn func([]VideoChange)) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.listeners = append(ix.listeners, fn)
+}
+
+func (ix *VideoIndex) notify(changes []VideoChange) {
+	if len(changes) == 0 {
+		return
+	}
+	ix.mu.Lock()
+	listeners := slices.Clone(ix.listeners)
+	ix.mu.Unlock()
+	for _, fn := range listeners {
+		fn(changes)
+	}
+}
+
+// diffEntries сравнивает записи по постоянному ID: новая запись или запись, у которой сменились
+// плейлист, папка, время изменения или версия расчёта, считается изменением
+func diffEntries(before, after map[string]*VideoEntry) []VideoChange {
+	previous := make(map[string]*VideoEntry, len(before))
+	for _, e := range before {
+		previous[e.ID] = e
+	}
+
+	var changes []VideoChange
+	seen := make(map[string]bool, len(after))
+	for _, e := range after {
+		seen[e.ID] = true
+		old, ok := previous[e.ID]
+		switch {
+		case !ok:
+			changes = append(changes, VideoChange{Kind: VideoAdded, ID: e.ID, Folder: e.Folder})
+		case old.PlaylistID != e.PlaylistID || old.Folder != e.Folder || !old.FolderModTime.Equal(e.FolderModTime) ||
+			!old.KeyframesModTime.Equal(e.KeyframesModTime) || old.ProbeVersion != e.ProbeVersion:
+			changes = append(changes, VideoChange{Kind: VideoUpdated, ID: e.ID, Folder: e.Folder})
+		}
+	}
+	for id, e := range previous {
+		if !seen[id] {
+			changes = append(changes, VideoChange{Kind: VideoRemoved, ID: id, Folder: e.Folder})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Folder < changes[j].Folder })
+	return changes
+}
+
+// Resolve находит папку видео по пути относительно baseDir или по постоянному ID
+func (ix *VideoIndex) Resolve(key string) (string, bool) {
+	if folder, ok := entity.CleanVideoPath(key); ok && ix.IsVideo(folder) {
+		return folder, true
+	}
+	if key == "" || strings.ContainsAny(key, "/.") {
+		return "", false
+	}
+
+	if folder, ok := ix.FindID(key); ok {
+		return folder, true
+	}
+	// папку могли переименовать после последнего обхода - пересобираем записи
+	if _, err := ix.List(); err != nil {
+		return "", false
+	}
+	return ix.FindID(key)
+}
+
+// FindID ищет ID среди записей индекса и проверяет, что папка всё ещё на месте.
+// В отличие от Resolve библиотеку заново не обходит.
+func (ix *VideoIndex) FindID(id string) (string, bool) {
+	ix.mu.Lock()
+	folder, derived := "", false
+	for _, e := range ix.entries {
+		if e.ID == id {
+			folder, derived = e.Folder, e.DerivedID
+			break
+		}
+	}
+	ix.mu.Unlock()
+	if folder == "" {
+		return "", false
+	}
+
+	sidecar, err := readSidecar(filepath.Join(ix.baseDir, folder))
+	if err != nil {
+		return "", false
+	}
+	if sidecar.ID == "" {
+		// ID без sidecar верен, пока папка на месте и не заменена другой с тем же путём
+		return folder, derived
+	}
+	return folder, sidecar.ID == id
+}
+
+// Rebuild отбрасывает индекс и заново считает метаданные всех видео
+func (ix *VideoIndex) Rebuild() (int, error) {
+	ix.mu.Lock()
+	ix.entries = map[string]*VideoEntry{}
+	ix.mu.Unlock()
+
+	entries, err := ix.Sync()
+	if err != nil {
+		return 0, err
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if err := ix.save(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// lookup находит актуальную запись для папки или считает её заново (probed = true).
+// Расчёт идёт без блокировки: разбор сегментов (или ffprobe) может идти долго.
+// Папку lookup не меняет: у видео без sidecar ID вычисляется (derivedID), а не записывается.
+func (ix *VideoIndex) lookup(folder string) (*VideoEntry, bool) {
+	folder, ok := entity.CleanVideoPath(folder)
+	if !ok {
+		return nil, false
+	}
+	info := entity.NewMediaInfo(ix.baseDir, folder)
+	playlist := info.Playlist()
+	if playlist == nil {
+		return nil, false
+	}
+	sidecar, err := readSidecar(info.EntryPath)
+	if err != nil {
+		log.Printf("❌ Failed to read %s in %s: %v", SidecarName, folder, err)
+		sidecar = &videoSidecar{}
+	}
+	stat, err := os.Stat(info.EntryPath)
+	if err != nil {
+		return nil, false
+	}
+	// кадры лежат во вложенной папке: их замена не сдвигает время изменения папки видео
+	var keyframesModTime time.Time
+	if kf, err := os.Stat(filepath.Join(info.EntryPath, "keyframes")); err == nil {
+		keyframesModTime = kf.ModTime()
+	}
+	id := playlist.ID()
+
+	ix.mu.Lock()
+	videoID, derived := sidecar.ID, sidecar.ID == ""
+	if derived {
+		videoID = ix.derivedID(info, id)
+	}
+	cached, ok := ix.entries[id]
+	ix.mu.Unlock()
+
+	createdAt := sidecar.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = stat.ModTime().UTC()
+	}
+	if ok && cached.ID == videoID && cached.DerivedID == derived && cached.Folder == folder &&
+		cached.FolderModTime.Equal(stat.ModTime()) && cached.KeyframesModTime.Equal(keyframesModTime) &&
+		cached.ProbeVersion == probeVersion {
+		fresh := *cached
+		fresh.CreatedAt = createdAt
+		fresh.Meta = sidecar.VideoMeta
+		return &fresh, false
+	}
+
+	return &VideoEntry{
+		ID:                 videoID,
+		PlaylistID:         id,
+		Folder:             strings.Clone(folder),
+		FolderModTime:      stat.ModTime(),
+		KeyframesModTime:   keyframesModTime,
+		Duration:           playlist.Duration(),
+		Resolution:         playlist.Resolution(),
+		SizeMB:             playlist.SizeMB(),
+		SegmentCount:       playlist.SegmentCount(),
+		AvgSegmentDuration: playlist.AvgSegmentDuration(),
+		Streams:            playlist.Streams(),
+		FrameHashes:        info.FrameHashes(),
+		DerivedID:          derived,
+		ProbeVersion:       probeVersion,
+		IndexedAt:          time.Now(),
+		CreatedAt:          createdAt,
+		Meta:               sidecar.VideoMeta,
+	}, true
+}
+
+// derivedID - ID видео без sidecar: прежний MediaInfo.ID() (хэш пути), чтобы уже выданные
+// ссылки не поменялись. Если этот ID уже носит другое видео (его папку переименовали, а на
+// старом месте появилась новая), берётся ID плейлиста. Вызывается под ix.mu.
+func (ix *VideoIndex) derivedID(info *entity.MediaInfo, playlistID string) string {
+	id := info.ID()
+	for _, e := range ix.entries {
+		if e.ID == id && e.Folder != info.Folder && !e.DerivedID {
+			return playlistID
+		}
+	}
+	return id
+}
+
+// resolveIDConflicts меняет вычисленный ID, если после обхода оказалось, что его уже носит
+// видео с sidecar: индекс мог ещё помнить переименованную папку по старому пути
+func resolveIDConflicts(entries []*VideoEntry) bool {
+	owned := map[string]bool{}
+	for _, e := range entries {
+		if !e.DerivedID {
+			owned[e.ID] = true
+		}
+	}
+	changed := false
+	for _, e := range entries {
+		if e.DerivedID && owned[e.ID] {
+			e.ID = e.PlaylistID
+			changed = true
+		}
+	}
+	return changed
+}
+
+// writeSidecars закрепляет вычисленные ID, записывая sidecar. Запись меняет время изменения
+// папки, поэтому запись индекса сдвигается на новое время, если папка не менялась с обхода.
+func (ix *VideoIndex) writeSidecars(entries []*VideoEntry) bool {
+	changed := false
+	for _, e := range entries {
+		if !e.DerivedID {
+			continue
+		}
+		dir := filepath.Join(ix.baseDir, e.Folder)
+		sidecar, err := readSidecar(dir)
+		if err != nil || sidecar.ID != "" {
+			// повреждённый sidecar не перезаписываем, чтобы не потерять метаданные пользователя
+			continue
+		}
+		before, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		sidecar.ID, sidecar.CreatedAt = e.ID, e.CreatedAt
+		if err := writeSidecar(dir, sidecar); err != nil {
+			log.Printf("❌ Failed to write %s in %s: %v", SidecarName, e.Folder, err)
+			continue
+		}
+		e.DerivedID = false
+		if after, err := os.Stat(dir); err == nil && before.ModTime().Equal(e.FolderModTime) {
+			e.FolderModTime = after.ModTime()
+		}
+		changed = true
+	}
+	return changed
+}
+
+func (ix *VideoIndex) save() error {
+	bytes, err := json.MarshalIndent(ix.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ix.path, bytes, 0644)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mediafs/internal/entity"
+)
+
+const (
+	maxTitleLen       = 256
+	maxDescriptionLen = 4096
+	maxTags           = 50
+	maxTagLen         = 64
+	maxFields         = 50
+	maxFieldKeyLen    = 64
+	maxFieldValueLen  = 1024
+)
+
+var ErrInvalidMeta = errors.New("invalid metadata")
+
+// VideoMeta - заданные пользователем название, описание, теги и произвольные поля видео
+type VideoMeta struct {
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+}
+
+// VideoMetaPatch - частичное изменение метаданных: отсутствующие поля не меняются,
+// tags заменяются целиком, а null в fields удаляет ключ.
+type VideoMetaPatch struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Tags        *[]string          `json:"tags"`
+	Fields      map[string]*string `json:"fields"`
+}
+
+func (p *VideoMetaPatch) apply(m *VideoMeta) error {
+	if p.Title != nil {
+		title := strings.TrimSpace(*p.Title)
+		if len(title) > maxTitleLen {
+			return fmt.Errorf("%w: title is longer than %d bytes", ErrInvalidMeta, maxTitleLen)
+		}
+		m.Title = title
+	}
+
+	if p.Description != nil {
+		if len(*p.Description) > maxDescriptionLen {
+			return fmt.Errorf("%w: description is longer than %d bytes", ErrInvalidMeta, maxDescriptionLen)
+		}
+		m.Description = *p.Description
+	}
+
+	if p.Tags != nil {
+		tags := make([]string, 0, len(*p.Tags))
+		for _, tag := range *p.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+				continue
+			}
+			if len(tag) > maxTagLen {
+				return fmt.Errorf("%w: tag %q is longer than %d bytes", ErrInvalidMeta, tag, maxTagLen)
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) > maxTags {
+			return fmt.Errorf("%w: more than %d tags", ErrInvalidMeta, maxTags)
+		}
+		m.Tags = tags
+	}
+
+	if len(p.Fields) > 0 {
+		fields := maps.Clone(m.Fields)
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		for key, value := range p.Fields {
+			key = strings.TrimSpace(key)
+			if key == "" || len(key) > maxFieldKeyLen {
+				return fmt.Errorf("%w: field key must be 1-%d bytes", ErrInvalidMeta, maxFieldKeyLen)
+			}
+			if value == nil {
+				delete(fields, key)
+				continue
+			}
+			if len(*value) > maxFieldValueLen {
+				return fmt.Errorf("%w: field %q is longer than %d bytes", ErrInvalidMeta, key, maxFieldValueLen)
+			}
+			fields[key] = *value
+		}
+		if len(fields) > maxFields {
+			return fmt.Errorf("%w: more than %d fields", ErrInvalidMeta, maxFields)
+		}
+		m.Fields = fields
+	}
+	return nil
+}
+
+// Meta возвращает пользовательские метаданные видео из его sidecar
+func (ix *VideoIndex) Meta(folder string) (*VideoMeta, error) {
+	sidecar, err := readSidecar(filepath.Join(ix.baseDir, folder))
+	if err != nil {
+		return nil, err
+	}
+	return &sidecar.VideoMeta, nil
+}
+
+// UpdateMeta применяет patch к sidecar видео. Запись sidecar меняет время изменения папки,
+// поэтому актуальная запись индекса сдвигается на новое время, а не пересчитывается заново.
+func (ix *VideoIndex) UpdateMeta(folder string, patch VideoMetaPatch) (*VideoMeta, error) {
+	meta, id, err := ix.updateMeta(folder, patch)
+	if err != nil {
+		return nil, err
+	}
+	ix.notify([]VideoChange{{Kind: VideoMetaSet, ID: id, Folder: folder}})
+	return meta, nil
+}
+
+func (ix *VideoIndex) updateMeta(folder string, patch VideoMetaPatch) (*VideoMeta, string, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	dir := filepath.Join(ix.baseDir, folder)
+	before, err := os.Stat(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sidecar, err := readSidecar(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if sidecar.ID == "" {
+		// закрепляем ID, под которым видео уже известно индексу
+		info := entity.NewMediaInfo(ix.baseDir, folder)
+		playlist := info.Playlist()
+		if playlist == nil {
+			return nil, "", os.ErrNotExist
+		}
+		sidecar.ID = ix.derivedID(info, playlist.ID())
+		for _, e := range ix.entries {
+			if e.Folder == folder && e.DerivedID {
+				sidecar.ID = e.ID
+			}
+		}
+	}
+	if sidecar.CreatedAt.IsZero() {
+		sidecar.CreatedAt = before.ModTime().UTC()
+	}
+	if err := patch.apply(&sidecar.VideoMeta); err != nil {
+		return nil, "", err
+	}
+	if err := writeSidecar(dir, sidecar); err != nil {
+		return nil, "", err
+	}
+
+	if after, err := os.Stat(dir); err == nil {
+		for key, e := range ix.entries {
+			if e.Folder == folder && e.FolderModTime.Equal(before.ModTime()) {
+				updated := *e
+				updated.FolderModTime = after.ModTime()
+				updated.DerivedID = false
+				ix.entries[key] = &updated
+			}
+		}
+		if err := ix.save(); err != nil {
+			log.Printf("❌ Failed to save video index: %v", err)
+		}
+	}
+	return &sidecar.VideoMeta, sidecar.ID, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SidecarName - служебный файл в папке видео. Он переезжает вместе с папкой,
+// поэтому хранящиеся в нём ID и пользовательские метаданные переживают переименование.
+const SidecarName = ".mediafs.json"
+
+type videoSidecar struct {
+	ID string `json:"id"`
+	// CreatedAt фиксируется при создании sidecar, потому что время изменения папки
+	// сдвигается при каждой записи в неё
+	CreatedAt time.Time `json:"created_at"`
+	VideoMeta
+}
+
+// readSidecar читает служебный файл; отсутствие файла даёт пустой sidecar
+func readSidecar(dir string) (*videoSidecar, error) {
+	content, err := os.ReadFile(filepath.Join(dir, SidecarName))
+	if os.IsNotExist(err) {
+		return &videoSidecar{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sidecar videoSidecar
+	if err := json.Unmarshal(content, &sidecar); err != nil {
+		return nil, err
+	}
+	return &sidecar, nil
+}
+
+func writeSidecar(dir string, sidecar *videoSidecar) error {
+	bytes, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, SidecarName), bytes, 0644)
+}
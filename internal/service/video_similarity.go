@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"sort"
+
+	"mediafs/internal/entity"
+)
+
+// DefaultFrameDistance - наибольшее расстояние Хэмминга (из 64 бит), при котором кадры считаются одинаковыми
+const DefaultFrameDistance = 10
+
+var (
+	ErrVideoNotFound = errors.New("video not found")
+	ErrNoFrameHashes = errors.New("video has no keyframes to compare")
+)
+
+// SimilarVideo - видео, кадры которого совпадают с кадрами исходного.
+// Score - доля совпавших кадров обоих видео, от 0 до 1.
+type SimilarVideo struct {
+	Entry         VideoEntry
+	Score         float64
+	MatchedFrames int
+}
+
+// Similar ранжирует остальные видео библиотеки по совпадению ключевых кадров с видео folder.
+// Находит перекодированные копии, у которых содержимое сегментов уже не совпадает побайтно.
+func (ix *VideoIndex) Similar(folder string, maxDistance int) ([]SimilarVideo, error) {
+	source, ok := ix.Get(folder)
+	if !ok {
+		return nil, ErrVideoNotFound
+	}
+	if len(source.FrameHashes) == 0 {
+		return nil, ErrNoFrameHashes
+	}
+
+	entries, err := ix.List()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SimilarVideo, 0)
+	for _, entry := range entries {
+		if entry.ID == source.ID || len(entry.FrameHashes) == 0 {
+			continue
+		}
+		matchedA := matchedFrames(source.FrameHashes, entry.FrameHashes, maxDistance)
+		if matchedA == 0 {
+			continue
+		}
+		matchedB := matchedFrames(entry.FrameHashes, source.FrameHashes, maxDistance)
+		result = append(result, SimilarVideo{
+			Entry:         entry,
+			Score:         float64(matchedA+matchedB) / float64(len(source.FrameHashes)+len(entry.FrameHashes)),
+			MatchedFrames: matchedA,
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Entry.Folder < result[j].Entry.Folder
+	})
+	return result, nil
+}
+
+// matchedFrames - сколько кадров из a имеют близкий кадр в b
+func matchedFrames(a, b []uint64, maxDistance int) int {
+	matched := 0
+	for _, x := range a {
+		for _, y := range b {
+			if entity.HammingDistance(x, y) <= maxDistance {
+				matched++
+				break
+			}
+		}
+	}
+	return matched
+}